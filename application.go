@@ -2,74 +2,279 @@
 	A card deck. Provides both a standard deck of 52 cards as well as custom decks made up of selected cards.
 	Exposes the deck as a rest API with the following endpoints.
 
+	/api/v1/deck-types				-> GET  -- Lists the types of deck that can be created, and their cards.
+	/api/v1/deck-types				-> POST -- Defines a new type of deck from the JSON definition in the body.
 	/api/v1/decks 						-> POST -- Creates a new deck and returns its salient details.
-	/api/v1/decks						-> GET  -- Returns a list of decks currently in the system.
+												   With type=t, creates a deck of that type rather than a standard one.
+												   With seed=n, shuffles it with that seed so the order can be reproduced.
+												   With jokers_enabled=true, adds the red and black jokers (RJ, BJ), for
+												   deck types that have them.
+												   With deck_count=n, combines n copies of the cards into one shoe.
+												   With shuffle_mode=secure, it is always shuffled from crypto/rand.
+												   With shuffle_mode=fair, publishes a hash of its server seed, and if
+												   shuffled, the client_seed used and a commitment to the order.
+												   With label=name:value (repeatable), labels the deck.
+												   The options can instead be sent as an application/json body, with
+												   the cards as a list and any metadata to keep with the deck.  Bodies
+												   of any other type are refused.
+	/api/v1/decks						-> GET  -- Returns a list of decks currently in the system, oldest first, a page
+												   of limit=n at a time.  Pass the next_cursor of a page as cursor=c to
+												   get the next one.  Filter with shuffled=true/false, empty=true/false,
+												   created_after=t, label=name:value, owner=o and tag=t.  With
+												   summary=true, gives each deck's details as well as its ID.
+	/api/v1/decks?ids=a,b,c				-> DELETE -- Deletes all the listed decks, or none of them if any do not exist.
 	/api/v1/decks/{id)					-> GET  -- Opens a deck, providing its details and the remaining cards in the deck.
-	/api/v1/decks/{id}/draw?number=x	-> POST -- Draws x cards from the deck, returning them and removing them from the deck.
+												   With drawn=true, also lists the cards drawn and not returned.
+	/api/v1/decks/{id}					-> PATCH -- Changes the deck's labels and metadata, from the JSON body
+												   {"labels": {...}, "metadata": {...}}.  Names set to null are removed.
+	/api/v1/decks/{id}					-> DELETE -- Deletes a deck.
+	/api/v1/decks/{id}/draw?count=x		-> POST -- Draws x cards from the deck, returning them and removing them from the deck.
+												   The count may also be given as number=x, and is one if not given.
+												   If fewer than x are left, those are drawn, unless strict=true, when
+												   nothing is drawn and the request fails.
+	/api/v1/decks/{id}/return?cards=x,y&position=p
+										-> POST -- Puts drawn cards back in the deck, on the top, bottom or at random.
+	/api/v1/decks/{id}/shuffle?mode=m	-> POST -- Shuffles the cards left in the deck (mode=remaining, the default), or
+												   gathers every drawn and piled card back and shuffles them all (mode=all).
+												   With seed=n, shuffles with that seed rather than a random one.
+												   Fair decks are shuffled just once, with client_seed=s, and deal
+												   nothing until they are.
+	/api/v1/decks/{id}/close			-> POST -- Closes a fair deck, revealing its server seed.  Nothing more is dealt
+												   from it, or returned to it.
+	/api/v1/decks/{id}/history			-> GET  -- The cards the deck started with and every draw and return since.
+												   With sources=true, says which deck of a shoe each card came from.
+	/api/v1/decks/{id}/piles			-> GET  -- Lists the deck's piles and how many cards are in each.
+	/api/v1/decks/{id}/piles/{name}		-> POST -- Creates a new, empty, pile.
+	/api/v1/decks/{id}/piles/{name}		-> GET  -- Lists the cards in a pile.
+	/api/v1/decks/{id}/piles/{name}/draw?count=x
+										-> POST -- Deals x cards from the deck onto the pile.
+	/api/v1/decks/{id}/piles/{name}/move?to=other&count=x (or &cards=x,y)
+										-> POST -- Moves cards from the top of the pile (or the listed cards) to another pile.
+	/api/v1/decks/{id}/piles/{name}/shuffle
+										-> POST -- Shuffles the pile.
+
+	Cards may be given in lower case, with T or 0 for a ten, and with the Unicode suite symbols (10h, TH, 10♥), but are
+	always sent back with their own codes.  Endpoints that send back cards also give each its Unicode playing card
+	glyph when asked with glyphs=true.  Only the French and Tarot decks have glyphs.
+
+	Failed requests are answered with a JSON error envelope, described in rest_errors.go.
+
+	Opening a deck sends its version as an ETag, and requests that change a deck can be made conditional on it with
+	If-Match, as described in conditional.go.
+
+	Creating a deck and drawing from one can be retried safely by sending an Idempotency-Key header, as described in
+	idempotency.go.
 */
 
 package toggleDecks
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gorilla/mux"
+	"io"
 	"log"
+	"mime"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Main application of the toggleDecks server.  Initializes the database and router, and optionally starts the server.
 type App struct {
 	Router *mux.Router
 
-	// In memory storage for all created decks.
-	TheDecks map[string]*Deck
+	// Storage for all created decks.
+	Store DeckStore
+
+	// How long a deck may sit untouched before it expires.  Zero means decks never expire.
+	IdleTTL time.Duration
+
+	// How long responses to requests with idempotency keys are kept to be replayed.  Zero means keys are ignored.
+	IdempotencyWindow time.Duration
+
+	// The idempotency keys decks were created with.
+	creationKeys creationKeys
+
+	// The deck types defined by users, as kept in the store.
+	deckTypes *deckTypeRegistry
 }
 
-// Create and initialize a new app (and database and router)
-func NewApp() *App {
-	a := App{mux.NewRouter(), map[string]*Deck{}}
+// Create and initialize a new app (and router) backed by the passed deck store.  If the store keeps deck types, any of
+// them with the same name as a built in type are logged and skipped, and the built in type is used instead.
+func NewApp(store DeckStore) *App {
+	a := App{Router: mux.NewRouter(), Store: store, IdempotencyWindow: DefaultIdempotencyWindow, deckTypes: newDeckTypeRegistry()}
+
+	// The store's deck types have to be known before any of its decks can be used.
+	if types, ok := store.(DeckTypeStore); ok {
+		for _, t := range types.ListDeckTypes() {
+			if err := a.addDeckType(t); err != nil {
+				_ = log.Output(1, "Skipping the stored deck type "+t.Name+": "+err.Error())
+			}
+		}
+	}
+
+	a.Router.Use(RequestIdMiddleware)
+	a.Router.NotFoundHandler = RequestIdMiddleware(http.HandlerFunc(NotFoundHandler))
+	a.Router.MethodNotAllowedHandler = RequestIdMiddleware(http.HandlerFunc(MethodNotAllowedHandler))
+
+	a.Router.HandleFunc("/api/v1/deck-types", a.DeckTypeListEndpoint).Methods("GET")
+	a.Router.HandleFunc("/api/v1/deck-types", a.DeckTypeCreateEndpoint).Methods("POST")
 	a.Router.HandleFunc("/api/v1/decks", a.DeckCreateEndpoint).Methods("POST")
 	a.Router.HandleFunc("/api/v1/decks", a.DeckListEndpoint).Methods("GET")
+	a.Router.HandleFunc("/api/v1/decks", a.DeckBulkDeleteEndpoint).Methods("DELETE")
 	a.Router.HandleFunc("/api/v1/decks/{deckId}", a.DeckOpenEndpoint).Methods("GET")
+	a.Router.HandleFunc("/api/v1/decks/{deckId}", a.DeckPatchEndpoint).Methods("PATCH")
+	a.Router.HandleFunc("/api/v1/decks/{deckId}", a.DeckDeleteEndpoint).Methods("DELETE")
 	a.Router.HandleFunc("/api/v1/decks/{deckId}/draw", a.DeckDrawEndpoint).Methods("POST")
+	a.Router.HandleFunc("/api/v1/decks/{deckId}/return", a.DeckReturnEndpoint).Methods("POST")
+	a.Router.HandleFunc("/api/v1/decks/{deckId}/shuffle", a.DeckShuffleEndpoint).Methods("POST")
+	a.Router.HandleFunc("/api/v1/decks/{deckId}/close", a.DeckCloseEndpoint).Methods("POST")
+	a.Router.HandleFunc("/api/v1/decks/{deckId}/history", a.DeckHistoryEndpoint).Methods("GET")
+	a.Router.HandleFunc("/api/v1/decks/{deckId}/piles", a.PileListEndpoint).Methods("GET")
+	a.Router.HandleFunc("/api/v1/decks/{deckId}/piles/{pileName}", a.PileCreateEndpoint).Methods("POST")
+	a.Router.HandleFunc("/api/v1/decks/{deckId}/piles/{pileName}", a.PileOpenEndpoint).Methods("GET")
+	a.Router.HandleFunc("/api/v1/decks/{deckId}/piles/{pileName}/draw", a.PileDrawEndpoint).Methods("POST")
+	a.Router.HandleFunc("/api/v1/decks/{deckId}/piles/{pileName}/move", a.PileMoveEndpoint).Methods("POST")
+	a.Router.HandleFunc("/api/v1/decks/{deckId}/piles/{pileName}/shuffle", a.PileShuffleEndpoint).Methods("POST")
 
 	return &a
 }
 
-// Run the server on the passed address.
+// Run the server on the passed address.  If decks expire, the reaper runs in the background, checking twice per TTL.
 func (a *App) Run(addr string) {
+	if a.IdleTTL > 0 {
+		a.StartReaper(a.IdleTTL / 2)
+	}
+
 	log.Fatal(http.ListenAndServe(addr, a.Router))
 }
 
-// Empty the mock database.
+// Empty the database, and forget the idempotency keys the decks were created with.
 func (a *App) ClearTheDatabase() {
-	a.TheDecks = map[string]*Deck{}
+	if err := a.Store.Clear(); err != nil {
+		_ = log.Output(1, "Error clearing the deck store: "+err.Error())
+	}
+	a.creationKeys.clear()
 }
 
-// Create a deck and file it the decks database.
+// The choices that can be made when creating a deck.
+type DeckOptions struct {
+	// Space separated codes of the cards in the deck.  Empty for a full deck.
+	Cards string
+
+	// Shuffle the deck after creating it.
+	Shuffle bool
+
+	// The seed to shuffle with.  If nil, a seed is generated.  Giving a seed implies Shuffle.
+	Seed *int64
+
+	// How the deck is shuffled, now and every time after.
+	ShuffleMode ShuffleMode
+
+	// The client seed to shuffle a fair deck with.  If empty, one is generated.
+	ClientSeed string
+
+	// How many copies of the cards to combine into a shoe.  Zero is the same as one.
+	DeckCount int
+
+	// Add the deck type's jokers to the cards, once for each deck in a shoe.
+	Jokers bool
+
+	// The name of the type of deck.  Empty for a standard deck.
+	Type string
+
+	// Names and values for finding the deck again.
+	Labels map[string]string
+
+	// Anything the creator wants kept with the deck.
+	Metadata map[string]interface{}
+}
+
+// Create a deck and file it the decks database.  Returns an empty ID if the deck could not be stored.
 func (a *App) NewDeck(cards string, shuffle bool) (iid string) {
-	iid = TheGuidProvider.GenerateIdentifier()
-	var deck Deck
+	iid, _, err := a.StoreNewDeck(DeckOptions{Cards: cards, Shuffle: shuffle})
+	if err != nil {
+		_ = log.Output(1, "Error storing new deck: "+err.Error())
+		return ""
+	}
+
+	return
+}
+
+// Create a deck as described by the options, and file it in the store under a freshly generated ID.
+func (a *App) StoreNewDeck(options DeckOptions) (iid string, deck *Deck, err error) {
+	deckType, ok := a.LookupDeckType(options.Type)
+	if !ok {
+		return "", nil, ErrUnknownDeckType
+	}
+
+	cards := options.Cards
 	if len(cards) == 0 {
-		deck = CreateFullDeck()
-	} else {
-		deck = CreateDeck(cards)
+		cards = deckType.Cards
+	}
+
+	if options.Jokers {
+		if len(deckType.Jokers) == 0 {
+			return "", nil, ErrNoJokers
+		}
+		cards += " " + deckType.Jokers
+	}
+
+	deck = CreateShoe(cards, options.DeckCount)
+	deck.ShuffleMode = options.ShuffleMode
+	deck.Labels = options.Labels
+	deck.Metadata = options.Metadata
+	if deckType.Name != StandardDeckTypeName {
+		deck.Type = deckType.Name
+	}
+	deck.deckType.Store(deckType)
+
+	if options.ShuffleMode == ShuffleFair {
+		if deck.Fair, err = NewFairShuffle(); err != nil {
+			return "", nil, err
+		}
 	}
 
-	if shuffle {
+	if options.ShuffleMode == ShuffleFair && options.Shuffle {
+		if err = deck.ShuffleFair(options.ClientSeed); err != nil {
+			return "", nil, err
+		}
+	} else if options.Seed != nil {
+		if err = deck.ShuffleWithSeed(*options.Seed); err != nil {
+			return "", nil, err
+		}
+	} else if options.Shuffle {
 		deck.Shuffle()
 	}
 
-	a.TheDecks[iid] = &deck
+	iid = TheGuidProvider.GenerateIdentifier()
+	if err = a.Store.Create(iid, deck); err != nil {
+		return "", nil, err
+	}
 
-	return
+	return iid, deck, nil
+}
+
+// Store the change being made to a deck.  If it can't be stored, the change is undone, so the deck is left as the
+// store has it.  Must be called between beginDeckUpdate and its done.
+func (a *App) updateDeck(iid string, deck *Deck) error {
+	err := a.Store.Update(iid, deck)
+	if err != nil && deck.undo != nil {
+		deck.restoreState(deck.undo)
+	}
+	return err
 }
 
 // Fetch a deck by it's ID.
 func (a *App) GetDeck(iid string) (deck *Deck, ok bool) {
-	deck, ok = a.TheDecks[iid]
+	if deck, ok = a.Store.Get(iid); ok && deck.Type != "" {
+		if t, known := a.LookupDeckType(deck.Type); known {
+			deck.deckType.Store(t)
+		}
+	}
 	return
 }
 
@@ -81,61 +286,261 @@ func (a *App) getDeckFromRequest(w http.ResponseWriter, r *http.Request) (iid st
 
 	iid, ok := pathParams["deckId"]
 	if !ok {
-		WriteError(w, http.StatusBadRequest, "Deck ID Required")
+		WriteError(w, http.StatusBadRequest, ErrorDeckIdRequired, "Deck ID Required")
 		return "", nil, fmt.Errorf("no Deck ID")
 	}
 
 	deck, ok = a.GetDeck(iid)
+	if ok && a.IdleTTL > 0 && deck.Expired(a.IdleTTL, TheClock.Now()) {
+		// Expired but not reaped yet, so finish the job.
+		_ = a.Store.Delete(iid)
+		ok = false
+	}
+
 	if !ok {
-		WriteError(w, http.StatusNotFound, fmt.Sprintf("ID %v is not a valid deck id.", iid))
+		WriteError(w, http.StatusNotFound, ErrorDeckNotFound, fmt.Sprintf("ID %v is not a valid deck id.", iid))
 		return "", nil, fmt.Errorf("deck ID does not reference a deck")
 	}
+
+	deck.Touch()
 	return iid, deck, nil
 }
 
+// Build the message describing a deck, including when it will expire if decks expire.
+func (a *App) deckMessage(iid string, deck *Deck, includeCards bool) RestDeckMessage {
+	message := NewRestDeckMessage(iid, deck, includeCards)
+	if a.IdleTTL > 0 {
+		expiresAt := deck.ExpiresAt(a.IdleTTL)
+		message.ExpiresAt = &expiresAt
+	}
+	return message
+}
+
+// Parse the card ids, which are legal if they are cards (or jokers) of the deck type.  If any of them are not, write an
+// error listing every one that isn't and return ok=false.
+// This is meant to be called as a helper from REST endpoints, it is not an endpoint itself.
+func parseCardIds(w http.ResponseWriter, deckType *DeckType, cardIds []string) (cards []Card, ok bool) {
+	cards, err := deckType.ParseCards(cardIds)
+	if err != nil {
+		var errs CardErrors
+		if errors.As(err, &errs) {
+			WriteErrorDetails(w, http.StatusBadRequest, ErrorBadCard, "Invalid Card Identifier.", NewRestCardErrors(errs))
+		} else {
+			WriteError(w, http.StatusBadRequest, ErrorBadCard, "Invalid Card Identifier.")
+		}
+		return nil, false
+	}
+
+	return cards, true
+}
+
+// The most cards that can be asked for in one draw: more than any deck can hold.
+const MaxDrawCount = MaxDeckCount * MaxDeckTypeSize
+
+// Read the number of cards to draw from the "count" parameter of a request, or its older name "number".  Without
+// either it is one card.  If it isn't a number from 1 to MaxDrawCount, or the two disagree, write an error and return
+// ok=false.
+// This is meant to be called as a helper from REST endpoints, it is not an endpoint itself.
+func getCountFromRequest(w http.ResponseWriter, r *http.Request) (count int, ok bool) {
+	query := r.URL.Query()
+	value, number := query.Get("count"), query.Get("number")
+	switch {
+	case len(value) == 0 && len(number) == 0:
+		return 1, true
+	case len(value) == 0:
+		value = number
+	case len(number) != 0 && number != value:
+		WriteError(w, http.StatusBadRequest, ErrorBadCount, fmt.Sprintf("count %v and number %v disagree.", value, number))
+		return 0, false
+	}
+
+	count, err := strconv.Atoi(value)
+	if err != nil || count < 1 || count > MaxDrawCount {
+		WriteError(w, http.StatusBadRequest, ErrorBadCount, fmt.Sprintf("The number of cards must be a number from 1 to %v, not %v.", MaxDrawCount, value))
+		return 0, false
+	}
+
+	return count, true
+}
+
+// Give each card its Unicode glyph, if the request asked for them with glyphs=true.
+// This is meant to be called as a helper from REST endpoints, it is not an endpoint itself.
+func addGlyphs(r *http.Request, deckType *DeckType, cards []RestCard) []RestCard {
+	if r.URL.Query().Get("glyphs") != "true" {
+		return cards
+	}
+
+	for i := range cards {
+		cards[i].Glyph = deckType.Glyph(Card(cards[i].Code))
+	}
+	return cards
+}
+
+// Read the optional "seed" parameter of a request.  Returns nil if there isn't one.  If it isn't a valid seed, write an
+// error and return ok=false.
+// This is meant to be called as a helper from REST endpoints, it is not an endpoint itself.
+func getSeedFromRequest(w http.ResponseWriter, r *http.Request) (seed *int64, ok bool) {
+	value := r.URL.Query().Get("seed")
+	if len(value) == 0 {
+		return nil, true
+	}
+
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, ErrorBadSeed, fmt.Sprintf("%v is not a valid shuffle seed.", value))
+		return nil, false
+	}
+
+	return &parsed, true
+}
+
+// The largest JSON deck creation request accepted, in bytes.
+const MaxDeckCreateRequestSize = 1024 * 1024
+
+// Read the request to create a deck, from the JSON body if the request has one, and otherwise from the query string.
+// If it can't be read, write an error and return ok=false.
+// This is meant to be called as a helper from REST endpoints, it is not an endpoint itself.
+func getCreateDeckRequest(w http.ResponseWriter, r *http.Request) (request RestCreateDeckRequest, ok bool) {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/json" {
+		decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxDeckCreateRequestSize))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&request); err != nil {
+			WriteError(w, http.StatusBadRequest, ErrorBadJson, fmt.Sprintf("Invalid deck creation request: %v", err))
+			return request, false
+		}
+		if decoder.More() {
+			WriteError(w, http.StatusBadRequest, ErrorBadJson, "Invalid deck creation request: more than one JSON value.")
+			return request, false
+		}
+		return request, true
+	}
+
+	if hasBody(r) {
+		WriteError(w, http.StatusUnsupportedMediaType, ErrorUnsupportedMediaType, "Deck creation requests with a body must send it as application/json.")
+		return request, false
+	}
+
+	query := r.URL.Query()
+	request = RestCreateDeckRequest{
+		Shuffle:       query.Get("shuffle") == "true",
+		Type:          query.Get("type"),
+		ShuffleMode:   query.Get("shuffle_mode"),
+		JokersEnabled: query.Get("jokers_enabled") == "true",
+		ClientSeed:    query.Get("client_seed"),
+	}
+
+	if custom := query.Get("cards"); len(custom) != 0 {
+		request.Cards = strings.Split(custom, ",")
+	}
+
+	if request.Labels, ok = getLabelsFromRequest(w, r); !ok {
+		return request, false
+	}
+
+	if request.Seed, ok = getSeedFromRequest(w, r); !ok {
+		return request, false
+	}
+
+	if count := query.Get("deck_count"); len(count) != 0 {
+		n, err := strconv.Atoi(count)
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, ErrorBadCount, fmt.Sprintf("deck_count must be a number from 1 to %v.", MaxDeckCount))
+			return request, false
+		}
+		request.DeckCount = &n
+	}
+
+	return request, true
+}
+
 // REST Endpoint for Creating a new deck
 func (a *App) DeckCreateEndpoint(w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query()
-	shuffled := query.Get("shuffle") == "true"
-	custom := query.Get("cards")
+	w, done, ok := a.beginIdempotentCreate(w, r)
+	if !ok {
+		return
+	}
+	defer done()
 
-	var iid string
+	request, ok := getCreateDeckRequest(w, r)
+	if !ok {
+		return
+	}
 
-	if len(custom) != 0 {
-		// Check if the cards are legal, which they are if the suite and ranks exist in the respective maps.
-		// We don't care if there is more than one of each card, etc, just that the collection is of actual card ids.
+	options := DeckOptions{Shuffle: request.Shuffle, Jokers: request.JokersEnabled, Seed: request.Seed, Labels: request.Labels, Metadata: request.Metadata}
 
-		cardIds := strings.Split(custom, ",")
-		for _, id := range cardIds {
-			pos := len(id) - 1
-			suite := id[pos:]
-			rank := id[:pos]
+	if err := ValidateLabels(options.Labels); err != nil {
+		WriteError(w, http.StatusBadRequest, ErrorBadLabels, err.Error())
+		return
+	}
+	if err := ValidateMetadata(options.Metadata); err != nil {
+		WriteError(w, http.StatusBadRequest, ErrorBadLabels, err.Error())
+		return
+	}
 
-			_, rankOk := RankMap[rank]
-			_, suiteOk := SuiteMap[suite]
+	mode, err := ParseShuffleMode(request.ShuffleMode)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, ErrorBadShuffleMode, err.Error())
+		return
+	}
+	options.ShuffleMode = mode
 
-			if !(rankOk && suiteOk) {
-				WriteError(w, http.StatusBadRequest, "Invalid Card Identifier.")
+	if mode != ShuffleSeeded && options.Seed != nil {
+		WriteError(w, http.StatusBadRequest, ErrorBadSeed, fmt.Sprintf("Decks in %v shuffle mode can not be shuffled with a seed.", mode))
+		return
+	}
 
-				if !rankOk {
-					_, _ = fmt.Fprintf(w, "%v is not a valid rank for a custom deck.", rank)
-				}
+	if request.DeckCount != nil {
+		if n := *request.DeckCount; n < 1 || n > MaxDeckCount {
+			WriteError(w, http.StatusBadRequest, ErrorBadCount, fmt.Sprintf("deck_count must be a number from 1 to %v.", MaxDeckCount))
+			return
+		}
+		options.DeckCount = *request.DeckCount
+	}
 
-				if !suiteOk {
-					_, _ = fmt.Fprintf(w, "%v is not a valid suite for a custom deck.", suite)
-				}
+	options.ClientSeed = request.ClientSeed
+	if len(options.ClientSeed) > MaxClientSeedLength {
+		WriteError(w, http.StatusBadRequest, ErrorBadSeed, fmt.Sprintf("Client seeds can be at most %v characters.", MaxClientSeedLength))
+		return
+	}
 
-				return
-			}
+	deckType, ok := a.LookupDeckType(request.Type)
+	if !ok {
+		WriteError(w, http.StatusBadRequest, ErrorUnknownDeckType, fmt.Sprintf("%v is not a known deck type.", request.Type))
+		return
+	}
+	options.Type = deckType.Name
+
+	if options.Jokers && len(deckType.Jokers) == 0 {
+		WriteError(w, http.StatusBadRequest, ErrorNoJokers, fmt.Sprintf("%v decks don't have jokers.", deckType.Name))
+		return
+	}
+
+	if len(request.Cards) > MaxDeckTypeSize {
+		WriteError(w, http.StatusBadRequest, ErrorBadCount, fmt.Sprintf("Custom decks can have at most %v cards.", MaxDeckTypeSize))
+		return
+	}
+
+	if len(request.Cards) != 0 {
+		// We don't care if there is more than one of each card, etc, just that the collection is of actual card ids.
+		cards, ok := parseCardIds(w, deckType, request.Cards)
+		if !ok {
+			return
 		}
 
-		iid = a.NewDeck(strings.Join(cardIds, " "), shuffled)
-	} else {
-		iid = a.NewDeck("", shuffled)
+		codes := make([]string, len(cards))
+		for i, c := range cards {
+			codes[i] = c.Code()
+		}
+		options.Cards = strings.Join(codes, " ")
 	}
-	deck, _ := a.GetDeck(iid)
 
-	WriteSuccess(w, NewRestDeckMessage(iid, deck, false))
+	iid, deck, err := a.StoreNewDeck(options)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrorStorageFailed, "Unable to store the new deck.")
+		return
+	}
+
+	WriteSuccess(w, a.deckMessage(iid, deck, false))
 }
 
 // REST endpoint for opening (i.e. listing) a deck.
@@ -145,35 +550,672 @@ func (a *App) DeckOpenEndpoint(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	WriteSuccess(w, NewRestDeckMessage(iid, deck, true))
+	if ifNoneMatch := r.Header.Get("If-None-Match"); len(ifNoneMatch) != 0 && etagMatches(ifNoneMatch, deck.ETag(), true) {
+		setETag(w, deck)
+		WriteNotModified(w)
+		return
+	}
+
+	message := a.deckMessage(iid, deck, true)
+	message.Cards = addGlyphs(r, deck.DeckType(), message.Cards)
+	if r.URL.Query().Get("drawn") == "true" {
+		message.Drawn = addGlyphs(r, deck.DeckType(), NewRestDrawMessage(deck.DeckType(), deck.Drawn()).Cards)
+	}
+
+	// The ETag is of the version sent, even if the deck has changed since.
+	w.Header().Set("ETag", versionETag(*message.Version))
+	WriteSuccess(w, message)
+}
+
+// REST endpoint for shuffling a deck, either just what is left in it or everything it started with.
+func (a *App) DeckShuffleEndpoint(w http.ResponseWriter, r *http.Request) {
+	iid, deck, err := a.getDeckFromRequest(w, r)
+	if err != nil {
+		return
+	}
+
+	done, ok := beginDeckUpdate(w, r, deck)
+	if !ok {
+		return
+	}
+	defer done()
+
+	seed, ok := getSeedFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	switch mode := r.URL.Query().Get("mode"); {
+	case deck.ShuffleMode == ShuffleFair && seed == nil:
+		// A fair shuffle always shuffles everything the deck started with, whatever mode is asked for.
+		err = deck.ShuffleFair(r.URL.Query().Get("client_seed"))
+	case mode == "" || mode == "remaining":
+		if seed == nil {
+			deck.Shuffle()
+		} else {
+			err = deck.ShuffleWithSeed(*seed)
+		}
+	case mode == "all":
+		if seed == nil {
+			deck.ShuffleAll()
+		} else {
+			err = deck.ShuffleAllWithSeed(*seed)
+		}
+	default:
+		WriteError(w, http.StatusBadRequest, ErrorBadShuffleMode, fmt.Sprintf("%v is not a valid shuffle mode.", mode))
+		return
+	}
+
+	if err == ErrFairShuffleDone {
+		WriteError(w, http.StatusConflict, ErrorAlreadyShuffled, "Fair decks can only be shuffled once.")
+		return
+	} else if err == ErrSeedNotAllowed {
+		WriteError(w, http.StatusBadRequest, ErrorBadSeed, fmt.Sprintf("Decks in %v shuffle mode can not be shuffled with a seed.", deck.ShuffleMode))
+		return
+	} else if err != nil {
+		WriteError(w, http.StatusBadRequest, ErrorBadSeed, err.Error())
+		return
+	}
+
+	if err := a.updateDeck(iid, deck); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrorStorageFailed, "Unable to store the deck after shuffling.")
+		return
+	}
+
+	setETag(w, deck)
+	WriteSuccess(w, a.deckMessage(iid, deck, false))
+}
+
+// REST endpoint for closing a fair deck, revealing its server seed so the shuffle can be checked.
+func (a *App) DeckCloseEndpoint(w http.ResponseWriter, r *http.Request) {
+	iid, deck, err := a.getDeckFromRequest(w, r)
+	if err != nil {
+		return
+	}
+
+	done, ok := beginDeckUpdate(w, r, deck)
+	if !ok {
+		return
+	}
+	defer done()
+
+	if err := deck.Reveal(); err != nil {
+		WriteError(w, http.StatusBadRequest, ErrorNotFair, "Only fair decks can be closed.")
+		return
+	}
+
+	if err := a.updateDeck(iid, deck); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrorStorageFailed, "Unable to store the deck after closing it.")
+		return
+	}
+
+	setETag(w, deck)
+	WriteSuccess(w, a.deckMessage(iid, deck, false))
+}
+
+// REST endpoint for the history of a deck.
+func (a *App) DeckHistoryEndpoint(w http.ResponseWriter, r *http.Request) {
+	iid, deck, err := a.getDeckFromRequest(w, r)
+	if err != nil {
+		return
+	}
+
+	WriteSuccess(w, NewRestHistoryMessage(iid, deck, r.URL.Query().Get("sources") == "true"))
 }
 
 // REST endpoint for drawing cards from a deck.
 func (a *App) DeckDrawEndpoint(w http.ResponseWriter, r *http.Request) {
-	_, deck, err := a.getDeckFromRequest(w, r)
+	iid, deck, err := a.getDeckFromRequest(w, r)
+	if err != nil {
+		return
+	}
+
+	w, done, ok := a.beginIdempotentDeckUpdate(w, r, iid, deck)
+	if !ok {
+		return
+	}
+	defer done()
+
+	count, ok := getCountFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := deck.CanDeal(); err != nil {
+		writeDealError(w, err)
+		return
+	}
+
+	var cards []Card
+	if r.URL.Query().Get("strict") == "true" {
+		if cards, err = deck.DrawExactly(count); err != nil {
+			WriteError(w, http.StatusConflict, ErrorNotEnoughCards, fmt.Sprintf("There are fewer than %v cards left in the deck.", count))
+			return
+		}
+	} else {
+		cards = deck.Draw(count)
+	}
+
+	// The deck is stored by done, along with the response if the request has an idempotency key.
+	message := NewRestDrawMessage(deck.DeckType(), cards)
+	message.Cards = addGlyphs(r, deck.DeckType(), message.Cards)
+	setETag(w, deck)
+	WriteSuccess(w, message)
+}
+
+// REST endpoint for putting drawn cards back in a deck.
+func (a *App) DeckReturnEndpoint(w http.ResponseWriter, r *http.Request) {
+	iid, deck, err := a.getDeckFromRequest(w, r)
 	if err != nil {
 		return
 	}
 
+	done, ok := beginDeckUpdate(w, r, deck)
+	if !ok {
+		return
+	}
+	defer done()
+
 	query := r.URL.Query()
-	count, err := strconv.Atoi(query.Get("count"))
+	returned := query.Get("cards")
+	if len(returned) == 0 {
+		WriteError(w, http.StatusBadRequest, ErrorMissingParameter, "Cards to return are required.")
+		return
+	}
+
+	cards, ok := parseCardIds(w, deck.DeckType(), strings.Split(returned, ","))
+	if !ok {
+		return
+	}
+
+	position := ReturnPosition(query.Get("position"))
+	if len(position) == 0 {
+		position = ReturnTop
+	}
+
+	if err := deck.Return(cards, position); err != nil {
+		if !writeDealError(w, err) {
+			WriteError(w, http.StatusBadRequest, ErrorBadRequest, err.Error())
+		}
+		return
+	}
+
+	if err := a.updateDeck(iid, deck); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrorStorageFailed, "Unable to store the deck after returning cards.")
+		return
+	}
+
+	setETag(w, deck)
+	WriteSuccess(w, a.deckMessage(iid, deck, false))
+}
+
+// REST endpoint for listing the types of deck that can be created.
+func (a *App) DeckTypeListEndpoint(w http.ResponseWriter, r *http.Request) {
+	types := a.DeckTypes()
+	message := RestDeckTypeListMessage{DeckTypes: make([]RestDeckTypeMessage, len(types))}
+	for i, t := range types {
+		message.DeckTypes[i] = NewRestDeckTypeMessage(t)
+	}
+
+	WriteSuccess(w, message)
+}
+
+// The largest deck type definition accepted, in bytes.
+const MaxDeckTypeDefinitionSize = 64 * 1024
+
+// REST endpoint for defining a new type of deck from a JSON definition in the request body.
+func (a *App) DeckTypeCreateEndpoint(w http.ResponseWriter, r *http.Request) {
+	var definition DeckTypeDefinition
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxDeckTypeDefinitionSize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&definition); err != nil {
+		WriteError(w, http.StatusBadRequest, ErrorBadDeckTypeDefinition, fmt.Sprintf("Invalid deck type definition: %v", err))
+		return
+	}
+
+	deckType, err := definition.Build()
 	if err != nil {
-		count = 1
+		WriteError(w, http.StatusBadRequest, ErrorBadDeckTypeDefinition, fmt.Sprintf("Invalid deck type definition: %v", err))
+		return
+	}
+
+	if _, ok := a.LookupDeckType(deckType.Name); ok {
+		WriteError(w, http.StatusConflict, ErrorDeckTypeExists, fmt.Sprintf("Deck type %v already exists.", deckType.Name))
+		return
+	}
+
+	types, ok := a.Store.(DeckTypeStore)
+	if !ok {
+		WriteError(w, http.StatusNotImplemented, ErrorDeckTypesNotStored, "The deck store can't keep deck types.")
+		return
+	}
+
+	if err := types.CreateDeckType(deckType); err == ErrDeckTypeExists {
+		WriteError(w, http.StatusConflict, ErrorDeckTypeExists, fmt.Sprintf("Deck type %v already exists.", deckType.Name))
+		return
+	} else if err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrorStorageFailed, "Unable to store the new deck type.")
+		return
+	}
+
+	// The store only lets one request file the name, so this can't clash.
+	_ = a.addDeckType(deckType)
+
+	WriteSuccess(w, NewRestDeckTypeMessage(deckType))
+}
+
+// Does the request have a body?  This may read some of it, so it is only for requests refused if they do.
+func hasBody(r *http.Request) bool {
+	if r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+		return false
+	}
+
+	n, _ := io.ReadFull(r.Body, make([]byte, 1))
+	return n > 0
+}
+
+// Read the optional true or false parameter of a request.  Returns nil if there isn't one.  If it is anything else,
+// write an error and return ok=false.
+// This is meant to be called as a helper from REST endpoints, it is not an endpoint itself.
+func getBoolFromRequest(w http.ResponseWriter, r *http.Request, name string) (value *bool, ok bool) {
+	switch r.URL.Query().Get(name) {
+	case "":
+		return nil, true
+	case "true":
+		value = new(bool)
+		*value = true
+		return value, true
+	case "false":
+		return new(bool), true
+	default:
+		WriteError(w, http.StatusBadRequest, ErrorBadFilter, fmt.Sprintf("%v must be true or false.", name))
+		return nil, false
+	}
+}
+
+// Read the "label" parameters of a request, each a label name and value separated by a colon.  Returns nil if there
+// aren't any.  If one has no colon, write an error and return ok=false.
+// This is meant to be called as a helper from REST endpoints, it is not an endpoint itself.
+func getLabelsFromRequest(w http.ResponseWriter, r *http.Request) (labels map[string]string, ok bool) {
+	for _, label := range r.URL.Query()["label"] {
+		name, value, found := strings.Cut(label, ":")
+		if !found {
+			WriteError(w, http.StatusBadRequest, ErrorBadLabels, fmt.Sprintf("Labels are given as name:value, not %v.", label))
+			return nil, false
+		}
+
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[name] = value
+	}
+
+	return labels, true
+}
+
+// Read the filter for listing decks from a request.  If it isn't valid, write an error and return ok=false.
+// This is meant to be called as a helper from REST endpoints, it is not an endpoint itself.
+func getDeckFilterFromRequest(w http.ResponseWriter, r *http.Request) (filter DeckFilter, ok bool) {
+	query := r.URL.Query()
+	filter.Owner = query.Get("owner")
+	filter.Tags = query["tag"]
+
+	if filter.Labels, ok = getLabelsFromRequest(w, r); !ok {
+		return
+	}
+
+	if filter.Shuffled, ok = getBoolFromRequest(w, r, "shuffled"); !ok {
+		return
+	}
+	if filter.Empty, ok = getBoolFromRequest(w, r, "empty"); !ok {
+		return
+	}
+
+	if after := query.Get("created_after"); len(after) != 0 {
+		t, err := time.Parse(time.RFC3339Nano, after)
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, ErrorBadFilter, fmt.Sprintf("created_after must be an RFC 3339 time, not %v.", after))
+			return filter, false
+		}
+		filter.CreatedAfter = &t
 	}
 
-	WriteSuccess(w, NewRestDrawMessage(deck.Draw(count)))
+	return filter, true
 }
 
 // REST endpoint for listing open decks
 func (a *App) DeckListEndpoint(w http.ResponseWriter, r *http.Request) {
-	allTheIds := make([]RestDeckMessage, len(a.TheDecks))
-	x := 0
-	for id, _ := range a.TheDecks {
-		allTheIds[x] = RestDeckMessage{id, nil, nil, []RestCard{}}
-		x++
+	query := r.URL.Query()
+
+	filter, ok := getDeckFilterFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	limit := DefaultDeckPageSize
+	if value := query.Get("limit"); len(value) != 0 {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > MaxDeckPageSize {
+			WriteError(w, http.StatusBadRequest, ErrorBadCount, fmt.Sprintf("limit must be a number from 1 to %v.", MaxDeckPageSize))
+			return
+		}
+		limit = n
+	}
+
+	listings, next, err := a.ListDecks(filter, query.Get("cursor"), limit)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, ErrorBadCursor, fmt.Sprintf("%v is not a valid cursor.", query.Get("cursor")))
+		return
+	}
+
+	summary := query.Get("summary") == "true"
+	decks := make([]RestDeckMessage, len(listings))
+	for i, listing := range listings {
+		if summary {
+			decks[i] = a.deckMessage(listing.Id, listing.Deck, false)
+			created := listing.Created
+			decks[i].Created = &created
+		} else {
+			decks[i] = RestDeckMessage{Id: listing.Id}
+		}
+	}
+
+	WriteSuccess(w, ListDeckMessage{Decks: decks, NextCursor: next})
+}
+
+// The largest JSON deck patch request accepted, in bytes.
+const MaxDeckPatchRequestSize = 64 * 1024
+
+// REST endpoint for changing a deck's labels and metadata.
+func (a *App) DeckPatchEndpoint(w http.ResponseWriter, r *http.Request) {
+	iid, deck, err := a.getDeckFromRequest(w, r)
+	if err != nil {
+		return
+	}
+
+	done, ok := beginDeckUpdate(w, r, deck)
+	if !ok {
+		return
+	}
+	defer done()
+
+	var request RestPatchDeckRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxDeckPatchRequestSize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
+		WriteError(w, http.StatusBadRequest, ErrorBadJson, fmt.Sprintf("Invalid deck patch request: %v", err))
+		return
+	}
+
+	if err := deck.Patch(request.Labels, request.Metadata); err != nil {
+		WriteError(w, http.StatusBadRequest, ErrorBadLabels, err.Error())
+		return
+	}
+
+	if err := a.updateDeck(iid, deck); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrorStorageFailed, "Unable to store the deck after patching it.")
+		return
+	}
+
+	setETag(w, deck)
+	WriteSuccess(w, a.deckMessage(iid, deck, false))
+}
+
+// REST endpoint for deleting a deck.
+func (a *App) DeckDeleteEndpoint(w http.ResponseWriter, r *http.Request) {
+	iid, _, err := a.getDeckFromRequest(w, r)
+	if err != nil {
+		return
+	}
+
+	// Someone else deleting it between the lookup and here still leaves it deleted, which is all that was asked.
+	if err := a.Store.Delete(iid); err != nil && err != ErrDeckNotFound {
+		WriteError(w, http.StatusInternalServerError, ErrorStorageFailed, "Unable to delete the deck.")
+		return
+	}
+
+	WriteNoContent(w)
+}
+
+// REST endpoint for deleting several decks at once.  Either every listed deck is deleted, or (if any of them don't
+// exist) none are.
+func (a *App) DeckBulkDeleteEndpoint(w http.ResponseWriter, r *http.Request) {
+	ids := r.URL.Query().Get("ids")
+	if len(ids) == 0 {
+		WriteError(w, http.StatusBadRequest, ErrorMissingParameter, "A list of deck IDs to delete is required.")
+		return
+	}
+
+	iids := strings.Split(ids, ",")
+	var missing []string
+	for _, iid := range iids {
+		if _, ok := a.GetDeck(iid); !ok {
+			missing = append(missing, iid)
+		}
 	}
 
-	message := ListDeckMessage{Decks: allTheIds}
+	if len(missing) != 0 {
+		WriteErrorDetails(w, http.StatusNotFound, ErrorDeckNotFound, fmt.Sprintf("IDs %v are not valid deck ids.", strings.Join(missing, ", ")), missing)
+		return
+	}
+
+	for _, iid := range iids {
+		if err := a.Store.Delete(iid); err != nil && err != ErrDeckNotFound {
+			WriteError(w, http.StatusInternalServerError, ErrorStorageFailed, fmt.Sprintf("Unable to delete deck %v.", iid))
+			return
+		}
+	}
+
+	WriteNoContent(w)
+}
+
+// Write the error for a deck that can't deal, if that's what err is, and return true.
+// This is meant to be called as a helper from REST endpoints, it is not an endpoint itself.
+func writeDealError(w http.ResponseWriter, err error) bool {
+	switch err {
+	case ErrFairDeckClosed:
+		WriteError(w, http.StatusConflict, ErrorFairDeckClosed, "The fair deck is closed: its server seed has been revealed.")
+	case ErrFairDeckNotShuffled:
+		WriteError(w, http.StatusConflict, ErrorFairDeckNotShuffled, "Fair decks must be shuffled before cards are dealt from them.")
+	default:
+		return false
+	}
+	return true
+}
+
+// Write the error for a failed pile operation.  Unknown piles are not found, decks that can't deal are in conflict,
+// and anything else was a bad request.
+// This is meant to be called as a helper from REST endpoints, it is not an endpoint itself.
+func writePileError(w http.ResponseWriter, name string, err error) {
+	if writeDealError(w, err) {
+		return
+	}
+
+	switch err {
+	case ErrPileNotFound:
+		WriteError(w, http.StatusNotFound, ErrorPileNotFound, fmt.Sprintf("%v is not a pile in this deck.", name))
+	case ErrPileExists:
+		WriteError(w, http.StatusConflict, ErrorPileExists, fmt.Sprintf("Pile %v already exists.", name))
+	default:
+		WriteError(w, http.StatusBadRequest, ErrorBadRequest, err.Error())
+	}
+}
+
+// REST endpoint for listing the piles of a deck.
+func (a *App) PileListEndpoint(w http.ResponseWriter, r *http.Request) {
+	iid, deck, err := a.getDeckFromRequest(w, r)
+	if err != nil {
+		return
+	}
+
+	WriteSuccess(w, NewRestPileListMessage(iid, deck))
+}
+
+// REST endpoint for creating a pile in a deck.
+func (a *App) PileCreateEndpoint(w http.ResponseWriter, r *http.Request) {
+	iid, deck, err := a.getDeckFromRequest(w, r)
+	if err != nil {
+		return
+	}
+
+	done, ok := beginDeckUpdate(w, r, deck)
+	if !ok {
+		return
+	}
+	defer done()
+
+	name := mux.Vars(r)["pileName"]
+	if err := deck.CreatePile(name); err != nil {
+		writePileError(w, name, err)
+		return
+	}
+
+	if err := a.updateDeck(iid, deck); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrorStorageFailed, "Unable to store the deck after creating a pile.")
+		return
+	}
+
+	setETag(w, deck)
+	WriteSuccess(w, NewRestPileMessage(iid, name, deck.DeckType(), []Card{}))
+}
+
+// REST endpoint for listing the cards in a pile.
+func (a *App) PileOpenEndpoint(w http.ResponseWriter, r *http.Request) {
+	iid, deck, err := a.getDeckFromRequest(w, r)
+	if err != nil {
+		return
+	}
+
+	name := mux.Vars(r)["pileName"]
+	cards, err := deck.GetPile(name)
+	if err != nil {
+		writePileError(w, name, err)
+		return
+	}
+
+	message := NewRestPileMessage(iid, name, deck.DeckType(), cards)
+	message.Cards = addGlyphs(r, deck.DeckType(), message.Cards)
+	WriteSuccess(w, message)
+}
+
+// REST endpoint for dealing cards from a deck onto one of its piles.
+func (a *App) PileDrawEndpoint(w http.ResponseWriter, r *http.Request) {
+	iid, deck, err := a.getDeckFromRequest(w, r)
+	if err != nil {
+		return
+	}
+
+	done, ok := beginDeckUpdate(w, r, deck)
+	if !ok {
+		return
+	}
+	defer done()
+
+	count, ok := getCountFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	name := mux.Vars(r)["pileName"]
+	cards, err := deck.DrawToPile(name, count)
+	if err != nil {
+		writePileError(w, name, err)
+		return
+	}
+
+	if err := a.updateDeck(iid, deck); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrorStorageFailed, "Unable to store the deck after dealing to a pile.")
+		return
+	}
+
+	message := NewRestDrawMessage(deck.DeckType(), cards)
+	message.Cards = addGlyphs(r, deck.DeckType(), message.Cards)
+	setETag(w, deck)
+	WriteSuccess(w, message)
+}
+
+// REST endpoint for moving cards from one pile to another.
+func (a *App) PileMoveEndpoint(w http.ResponseWriter, r *http.Request) {
+	iid, deck, err := a.getDeckFromRequest(w, r)
+	if err != nil {
+		return
+	}
+
+	done, ok := beginDeckUpdate(w, r, deck)
+	if !ok {
+		return
+	}
+	defer done()
+
+	query := r.URL.Query()
+	to := query.Get("to")
+	if len(to) == 0 {
+		WriteError(w, http.StatusBadRequest, ErrorMissingParameter, "The pile to move cards to is required.")
+		return
+	}
+
+	// The count is only used when the cards aren't listed.
+	var cards []Card
+	var count int
+	if listed := query.Get("cards"); len(listed) != 0 {
+		if cards, ok = parseCardIds(w, deck.DeckType(), strings.Split(listed, ",")); !ok {
+			return
+		}
+	} else if count, ok = getCountFromRequest(w, r); !ok {
+		return
+	}
+
+	name := mux.Vars(r)["pileName"]
+	moved, err := deck.MovePileCards(name, to, count, cards)
+	if err == ErrPileNotFound {
+		// Either end of the move could be the missing pile.
+		if _, sourceErr := deck.GetPile(name); sourceErr == nil {
+			name = to
+		}
+	}
+	if err != nil {
+		writePileError(w, name, err)
+		return
+	}
+
+	if err := a.updateDeck(iid, deck); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrorStorageFailed, "Unable to store the deck after moving cards.")
+		return
+	}
+
+	message := NewRestDrawMessage(deck.DeckType(), moved)
+	message.Cards = addGlyphs(r, deck.DeckType(), message.Cards)
+	setETag(w, deck)
+	WriteSuccess(w, message)
+}
+
+// REST endpoint for shuffling a pile.
+func (a *App) PileShuffleEndpoint(w http.ResponseWriter, r *http.Request) {
+	iid, deck, err := a.getDeckFromRequest(w, r)
+	if err != nil {
+		return
+	}
+
+	done, ok := beginDeckUpdate(w, r, deck)
+	if !ok {
+		return
+	}
+	defer done()
+
+	name := mux.Vars(r)["pileName"]
+	if err := deck.ShufflePile(name); err != nil {
+		writePileError(w, name, err)
+		return
+	}
+
+	if err := a.updateDeck(iid, deck); err != nil {
+		WriteError(w, http.StatusInternalServerError, ErrorStorageFailed, "Unable to store the deck after shuffling a pile.")
+		return
+	}
 
+	cards, _ := deck.GetPile(name)
+	message := NewRestPileMessage(iid, name, deck.DeckType(), cards)
+	message.Cards = addGlyphs(r, deck.DeckType(), message.Cards)
+	setETag(w, deck)
 	WriteSuccess(w, message)
 }
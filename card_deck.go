@@ -4,15 +4,26 @@
 	A deck contains a number of cards (identified by a character code).  Decks are created in a standard order, and can
 	be shuffled after creation.  They also know how many cards they have remaining, and if they are shuffled or not.
 
+	A deck can also be a shoe: several copies of the same cards combined.  Each card in a shoe remembers which of the
+	copies it came from, though it looks just like every other card with the same code.
+
 	A deck maintains un-drawn cards only, meaning that when you draw from a deck, the cards that are returned are removed
 	from the deck, and the size of the deck decreases appropriately.
+
+	Decks are safe for concurrent use.  Every operation on a deck holds that deck's lock, so simultaneous draws on the
+	same deck are serialized while draws on different decks run in parallel.
 */
 
 package toggleDecks
 
 import (
-	"math/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -20,14 +31,31 @@ import (
 // Spades, Diamonds, Clubs, Hearts.  This is used to generate a standard "french" deck of cards.
 const STANDARD_DECK = "AS 2S 3S 4S 5S 6S 7S 8S 9S 10S JS QS KS AD 2D 3D 4D 5D 6D 7D 8D 9D 10D JD QD KD AC 2C 3C 4C 5C 6C 7C 8C 9C 10C JC QC KC AH 2H 3H 4H 5H 6H 7H 8H 9H 10H JH QH KH"
 
+// The codes of the two jokers, red then black, which can be added to the end of a deck.
+const JOKERS = "RJ BJ"
+
 // Map the suite code to the full name of the suite.
 var SuiteMap = map[string]string{"S": "SPADES", "D": "DIAMONDS", "C": "CLUBS", "H": "HEARTS"}
 
 // Map the Rank Codes to the full name of the rank.  This is identical to the code except for face cards.
-var RankMap = map[string]string{"A": "ACE", "1": "1", "2": "2", "3": "3", "4": "4", "5": "5", "6": "6", "7": "7", "8": "8", "9": "9", "10": "10", "J": "JACK", "Q": "QUEEN", "K": "KING"}
+var RankMap = map[string]string{"A": "ACE", "2": "2", "3": "3", "4": "4", "5": "5", "6": "6", "7": "7", "8": "8", "9": "9", "10": "10", "J": "JACK", "Q": "QUEEN", "K": "KING"}
+
+// The most decks that can be combined into a shoe.
+const MaxDeckCount = 16
+
+// Returned when asked to draw exactly more cards than are left in the deck.
+var ErrNotEnoughCards = errors.New("not enough cards left in the deck")
+
+// Separates the code of a card in a shoe from the number of the deck it came from.
+const sourceMark = "#"
+
+// Map the joker codes to the full names of the jokers.  Jokers have no rank or suite of their own, so their whole code
+// is looked up here, and their name is given as their rank.
+var JokerMap = map[string]string{"RJ": "RED JOKER", "BJ": "BLACK JOKER"}
 
 // A single playing card.  It is a string representing the card code, the last character of which is the suite, and the
-// first 1 or 2 characters of which are the rank.
+// first 1 or 2 characters of which are the rank, or which is one of the joker codes.  A card in a shoe follows its
+// code with a # and the number of the deck it came from.
 type Card string
 
 func (c Card) String() string {
@@ -35,32 +63,198 @@ func (c Card) String() string {
 }
 
 func (c Card) Code() string {
-	return string(c)
+	code, _, _ := strings.Cut(string(c), sourceMark)
+	return code
+}
+
+// The number of the deck in a shoe that the card came from, counting from 1.  Cards not from a shoe are all from deck 1.
+func (c Card) Source() int {
+	_, source, ok := strings.Cut(string(c), sourceMark)
+	if !ok {
+		return 1
+	}
+
+	n, _ := strconv.Atoi(source)
+	return n
 }
 
+// The full name of the card's rank in a standard deck.  Decks of other types name their cards with their DeckType.
 func (c Card) Rank() string {
 	code := c.Code()
+	if joker, ok := JokerMap[code]; ok {
+		return joker
+	}
 	return RankMap[code[:len(code)-1]]
 }
 
+// The full name of the card's suite in a standard deck.  Empty for jokers, which don't have one.
 func (c Card) Suite() string {
+	if c.IsJoker() {
+		return ""
+	}
+
 	code := c.Code()
 	return SuiteMap[code[len(code)-1:]]
 }
 
-// A deck of cards.
+// Is the card one of the jokers?
+func (c Card) IsJoker() bool {
+	_, ok := JokerMap[c.Code()]
+	return ok
+}
+
+// Where in the deck returned cards are put.
+type ReturnPosition string
+
+const (
+	ReturnTop    ReturnPosition = "top"
+	ReturnBottom ReturnPosition = "bottom"
+	ReturnRandom ReturnPosition = "random"
+)
+
+// The things that can happen to a deck that are kept in its history.
+const (
+	EventDraw    = "draw"
+	EventReturn  = "return"
+	EventCollect = "collect"
+)
+
+// A single entry in a deck's history: a draw from it, a return of cards to it, a deal or move of cards to a pile, or
+// the collection of every card back into the deck.
+type DeckEvent struct {
+	Time     time.Time      `json:"time"`
+	Action   string         `json:"action"`
+	Count    int            `json:"count"`
+	Cards    []Card         `json:"cards"`
+	Position ReturnPosition `json:"position,omitempty"`
+	From     string         `json:"from,omitempty"`
+	Pile     string         `json:"pile,omitempty"`
+}
+
+// A deck of cards.  Decks hold a lock, so they must always be handled by pointer.
 type Deck struct {
-	Cards    []Card
-	Shuffled bool
+	Cards        []Card           `json:"cards"`
+	Original     []Card           `json:"original"`
+	History      []DeckEvent      `json:"history"`
+	Piles        map[string]*Pile `json:"piles,omitempty"`
+	Shuffled     bool             `json:"shuffled"`
+	Seed         int64            `json:"seed"`
+	ShuffleMode  ShuffleMode      `json:"shuffle_mode,omitempty"`
+	Type         string           `json:"type,omitempty"`
+	Fair         *FairShuffle     `json:"fair,omitempty"`
+	Created      time.Time        `json:"created"`
+	LastAccessed time.Time        `json:"last_accessed"`
+
+	// Counts the changes made to the deck, so clients can tell whether it has changed since they last saw it.
+	Version int64 `json:"version"`
+
+	// Names and values for finding the deck again, and anything the creator of the deck wants kept with it.
+	Labels   map[string]string      `json:"labels,omitempty"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+
+	// The responses to draws with idempotency keys, by key, so they can be replayed.
+	IdempotentResponses map[string]IdempotentResponse `json:"idempotent_responses,omitempty"`
+
+	mu sync.Mutex
+
+	// Held by requests changing the deck, from checking which version it is through to storing the change.
+	update sync.Mutex
+
+	// The deck as it was before the change being made under the update lock, so it can be undone if it can't be stored.
+	undo *deckState
+
+	// Set when the deck is used, so the reaper knows to store the new access time.
+	touched bool
+
+	// The deck's type, filled in by the app that created or fetched the deck, since user defined types belong to it.
+	deckType atomic.Pointer[DeckType]
+}
+
+// Everything about a deck that can be changed, copied so that a change can be undone.
+type deckState struct {
+	cards               []Card
+	history             []DeckEvent
+	piles               map[string]*Pile
+	shuffled            bool
+	seed                int64
+	fair                *FairShuffle
+	version             int64
+	labels              map[string]string
+	metadata            map[string]interface{}
+	idempotentResponses map[string]IdempotentResponse
+}
+
+// Copy the deck as it is now.
+func (d *Deck) saveState() *deckState {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// History is only ever appended to, and labels and metadata are replaced rather than changed, so they needn't be
+	// copied.  Everything else can be changed in place.
+	s := &deckState{
+		cards:    append([]Card{}, d.Cards...),
+		history:  d.History,
+		shuffled: d.Shuffled,
+		seed:     d.Seed,
+		version:  d.Version,
+		labels:   d.Labels,
+		metadata: d.Metadata,
+	}
+
+	if d.Piles != nil {
+		s.piles = make(map[string]*Pile, len(d.Piles))
+		for name, pile := range d.Piles {
+			s.piles[name] = &Pile{Cards: append([]Card{}, pile.Cards...)}
+		}
+	}
+
+	if d.Fair != nil {
+		fair := *d.Fair
+		s.fair = &fair
+	}
+
+	if d.IdempotentResponses != nil {
+		s.idempotentResponses = make(map[string]IdempotentResponse, len(d.IdempotentResponses))
+		for key, response := range d.IdempotentResponses {
+			s.idempotentResponses[key] = response
+		}
+	}
+
+	return s
+}
+
+// Put the deck back the way it was when the state was saved.
+func (d *Deck) restoreState(s *deckState) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.Cards, d.History, d.Piles = s.cards, s.history, s.piles
+	d.Shuffled, d.Seed, d.Fair, d.Version = s.shuffled, s.seed, s.fair, s.version
+	d.Labels, d.Metadata, d.IdempotentResponses = s.labels, s.metadata, s.idempotentResponses
+}
+
+// Serialize the deck to json while holding its lock, so that a deck can be saved while other requests are using it.
+func (d *Deck) MarshalJSON() ([]byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	type plainDeck Deck
+	return json.Marshal((*plainDeck)(d))
 }
 
 // The number of cards left in the deck.
 func (d *Deck) Len() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
 	return len(d.Cards)
 }
 
 // Converts the deck to a string of space separated card codes.
 func (d *Deck) String() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
 	s := ""
 	for _, c := range d.Cards {
 		s += c.String() + " "
@@ -73,43 +267,282 @@ func (d *Deck) String() string {
 	}
 }
 
-// Swaps two cards in the deck by index.  Required for shuffle.
+// Record that the deck is in use, so it doesn't expire.  The access time is only kept in memory; it is stored along
+// with the next change to the deck, or by the reaper.
+func (d *Deck) Touch() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.LastAccessed = TheClock.Now()
+	d.touched = true
+}
+
+// Has the deck been used since this was last asked?
+func (d *Deck) takeTouched() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	touched := d.touched
+	d.touched = false
+	return touched
+}
+
+// The time at which the deck will expire if it is left idle for ttl.
+func (d *Deck) ExpiresAt(ttl time.Duration) time.Time {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.LastAccessed.Add(ttl)
+}
+
+// Has the deck been idle for longer than ttl as of now?
+func (d *Deck) Expired(ttl time.Duration, now time.Time) bool {
+	return now.After(d.ExpiresAt(ttl))
+}
+
+// Swaps two cards in the deck by index.  Required for shuffle, which already holds the lock, so Swap does not take it.
 func (d *Deck) Swap(i, j int) {
 	d.Cards[i], d.Cards[j] = d.Cards[j], d.Cards[i]
 }
 
-// Shuffle the deck, rearranging the cards in place.
+// Shuffle the deck, rearranging the cards in place.  A seeded deck gets a freshly generated seed.
 func (d *Deck) Shuffle() {
-	r := rand.New(rand.NewSource(time.Now().Unix()))
-	r.Shuffle(d.Len(), d.Swap)
-	d.Shuffled = true
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_ = d.shuffle(nil)
+}
+
+// Shuffle the deck with the passed seed, rearranging the cards in place.  The same cards shuffled with the same seed
+// always come out in the same order.  Only seeded decks can be shuffled with a seed.
+func (d *Deck) ShuffleWithSeed(seed int64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.shuffle(&seed)
+}
+
+// Gather every card back into the deck, both those drawn and those in piles, and shuffle the deck's full original
+// composition.  The piles are kept, but left empty.
+func (d *Deck) ShuffleAll() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_ = d.shuffleAll(nil)
+}
+
+// As ShuffleAll, but shuffling with the passed seed.  Only seeded decks can be shuffled with a seed; others are left
+// untouched if asked to.
+func (d *Deck) ShuffleAllWithSeed(seed int64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.shuffleAll(&seed)
+}
+
+// Gather every card back and shuffle them, unless the deck can't be shuffled.  Must be called with the lock held.
+func (d *Deck) shuffleAll(seed *int64) error {
+	if err := d.checkShuffle(seed); err != nil {
+		return err
+	}
+
+	d.collect()
+	return d.shuffle(seed)
+}
+
+// Put every card back in the deck in its original order.  Must be called with the lock held.
+func (d *Deck) collect() {
+	collected := len(d.Original) - len(d.Cards)
+	d.Cards = append([]Card{}, d.Original...)
+	for _, pile := range d.Piles {
+		pile.Cards = []Card{}
+	}
+	d.History = append(d.History, DeckEvent{Time: TheClock.Now(), Action: EventCollect, Count: collected, Cards: []Card{}})
 }
 
-// Draw the requested number of cards from the "top" of the deck.  Removes the drawn cards from the deck.
+// Draw the requested number of cards from the "top" of the deck.  Removes the drawn cards from the deck.  If there
+// aren't that many left, all that are left are drawn; a negative number draws none.  Nothing is drawn from a deck that
+// can't deal; check CanDeal to tell why.
 func (d *Deck) Draw(number int) (cards []Card) {
-	if number > d.Len() {
-		number = d.Len()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.checkDeal() != nil {
+		return []Card{}
+	}
+	return d.draw(number)
+}
+
+// Draw exactly the requested number of cards from the "top" of the deck.  If there aren't that many left, nothing is
+// drawn and ErrNotEnoughCards is returned.  Nothing is drawn from a deck that can't deal either.
+func (d *Deck) DrawExactly(number int) (cards []Card, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.checkDeal(); err != nil {
+		return nil, err
+	}
+
+	if number > len(d.Cards) {
+		return nil, ErrNotEnoughCards
+	}
+	return d.draw(number), nil
+}
+
+// Draw up to the requested number of cards.  Must be called with the lock held.
+func (d *Deck) draw(number int) (cards []Card) {
+	if number > len(d.Cards) {
+		number = len(d.Cards)
+	}
+	if number < 0 {
+		number = 0
 	}
 
 	cards = d.Cards[:number]
 	d.Cards = d.Cards[number:]
+	d.History = append(d.History, DeckEvent{Time: TheClock.Now(), Action: EventDraw, Count: len(cards), Cards: cards})
+	d.revealIfExhausted()
+	d.Version++
 
 	return
 }
 
+// The cards the deck was created with, and everything that has happened to it since, oldest first.
+func (d *Deck) GetHistory() (original []Card, history []DeckEvent) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return append([]Card{}, d.Original...), append([]DeckEvent{}, d.History...)
+}
+
+// The cards that have been drawn from the deck and not returned, in the order they were drawn.
+func (d *Deck) Drawn() (drawn []Card) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	drawn = []Card{}
+	for _, event := range d.History {
+		switch event.Action {
+		case EventDraw:
+			drawn = append(drawn, event.Cards...)
+		case EventCollect:
+			drawn = []Card{}
+		case EventReturn:
+			for _, c := range event.Cards {
+				for i := range drawn {
+					if drawn[i] == c {
+						drawn = append(drawn[:i], drawn[i+1:]...)
+						break
+					}
+				}
+			}
+		}
+	}
+
+	return
+}
+
+// Put previously drawn cards back in the deck, at the top (in the order given, so the first card returned is the next
+// drawn), at the bottom, or each at a random position.  Every card must be one that was in the deck when it was created
+// and is not in it or one of its piles now; if any is not, nothing is returned to the deck.  Closed fair decks take
+// nothing back.
+func (d *Deck) Return(cards []Card, position ReturnPosition) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.checkDeal(); err != nil {
+		return err
+	}
+
+	// Count the cards that are out of the deck: those in the original composition less those still here or in piles.
+	out := map[Card]int{}
+	for _, c := range d.Original {
+		out[c]++
+	}
+	for _, c := range d.Cards {
+		out[c]--
+	}
+	for _, pile := range d.Piles {
+		for _, c := range pile.Cards {
+			out[c]--
+		}
+	}
+
+	// Cards are returned by code, so in a shoe, take the first copy out of the deck with each code asked for.
+	returned := make([]Card, len(cards))
+	for i, c := range cards {
+		for _, o := range d.Original {
+			if o.Code() == c.Code() && out[o] > 0 {
+				returned[i] = o
+				break
+			}
+		}
+
+		if returned[i] == "" {
+			return fmt.Errorf("%v was not drawn from this deck", c)
+		}
+		out[returned[i]]--
+	}
+	cards = returned
+
+	remaining := make([]Card, 0, len(d.Cards)+len(cards))
+	switch position {
+	case ReturnTop:
+		remaining = append(append(remaining, cards...), d.Cards...)
+	case ReturnBottom:
+		remaining = append(append(remaining, d.Cards...), cards...)
+	case ReturnRandom:
+		remaining = append(remaining, d.Cards...)
+		r := d.random()
+		for _, c := range cards {
+			at := r.Intn(len(remaining) + 1)
+			remaining = append(remaining, "")
+			copy(remaining[at+1:], remaining[at:])
+			remaining[at] = c
+		}
+	default:
+		return fmt.Errorf("%v is not a valid position to return cards to", position)
+	}
+
+	d.Cards = remaining
+	d.History = append(d.History, DeckEvent{Time: TheClock.Now(), Action: EventReturn, Count: len(cards), Cards: cards, Position: position})
+	d.Version++
+	return nil
+}
+
 // Create a standard 52 card "French" Deck of playing cards.
-func CreateFullDeck() Deck {
+func CreateFullDeck() *Deck {
 	return CreateDeck(STANDARD_DECK)
 }
 
+// Create a shoe of count copies of the specified cards, each card marked with the copy it came from.  A shoe of one
+// deck is just the deck.
+func CreateShoe(includedCards string, count int) *Deck {
+	if count <= 1 {
+		return CreateDeck(includedCards)
+	}
+
+	single := strings.Split(includedCards, " ")
+	codes := make([]string, 0, len(single)*count)
+	for n := 1; n <= count; n++ {
+		for _, code := range single {
+			codes = append(codes, code+sourceMark+strconv.Itoa(n))
+		}
+	}
+
+	return CreateDeck(strings.Join(codes, " "))
+}
+
 // Create a new card deck containing the specified cards.
 // Does NOT check if the cards are "valid" card codes for any given type of deck, that should be done by the caller.
-func CreateDeck(includedCards string) (cards Deck) {
+func CreateDeck(includedCards string) (cards *Deck) {
 	cardCodes := strings.Split(includedCards, " ")
-	cards = Deck{make([]Card, len(cardCodes)), false}
+	now := TheClock.Now()
+	cards = &Deck{Cards: make([]Card, len(cardCodes)), Created: now, LastAccessed: now}
 	for idx, code := range cardCodes {
 		cards.Cards[idx] = Card(code)
 	}
+	cards.Original = append([]Card{}, cards.Cards...)
 
 	return
 }
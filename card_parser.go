@@ -0,0 +1,192 @@
+/*
+	Parsing card codes given by users.
+
+	Parsing is strict: a code must be the code of a card that can be in a deck of the type, with nothing around it.  A
+	few common alternative notations are accepted and turned into the card's own code: lower case letters ("10h"), T or
+	0 for a ten ("TH", "0H") and the Unicode suite symbols ("10♥", "10♡").  When codes are refused, the error says which
+	code, where in the list it was, and what was wrong with it.
+*/
+
+package toggleDecks
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// The reasons a card code can be refused.  A *CardError wraps one of them, so they can be checked for with errors.Is.
+var (
+	ErrEmptyCardCode = errors.New("empty card code")
+	ErrUnknownRank   = errors.New("unknown rank")
+	ErrUnknownSuite  = errors.New("unknown suite")
+	ErrUnknownCard   = errors.New("unknown rank and suite")
+	ErrCardNotInDeck = errors.New("card is not in this type of deck")
+)
+
+// A card code that could not be parsed.
+type CardError struct {
+	// The code as it was given.
+	Code string
+
+	// Where the code was in the list it was parsed from, counting from 0.
+	Position int
+
+	// Why it was refused: one of the Err... reasons above.
+	Err error
+}
+
+func (e *CardError) Error() string {
+	return fmt.Sprintf("card %d (%q): %v", e.Position, e.Code, e.Err)
+}
+
+func (e *CardError) Unwrap() error {
+	return e.Err
+}
+
+// Every code in a list that could not be parsed, in the order they appeared.
+type CardErrors []*CardError
+
+func (e CardErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Parse the code of a card in a standard deck, jokers included.
+func ParseCard(code string) (Card, error) {
+	return standardDeckType().ParseCard(code)
+}
+
+// Parse a list of codes of cards in a standard deck, jokers included.
+func ParseCards(codes []string) ([]Card, error) {
+	return standardDeckType().ParseCards(codes)
+}
+
+// Parse the code of a card that can be in a deck of this type, jokers included.  A refused code is reported as a
+// *CardError at position 0.
+func (t *DeckType) ParseCard(code string) (Card, error) {
+	card, err := t.parseCode(code)
+	if err != nil {
+		return "", &CardError{Code: code, Err: err}
+	}
+	return card, nil
+}
+
+// Parse a list of codes of cards that can be in a deck of this type, jokers included.  If any are refused, the error is
+// the CardErrors for every one of them, and no cards are returned.
+func (t *DeckType) ParseCards(codes []string) ([]Card, error) {
+	cards := make([]Card, len(codes))
+	var errs CardErrors
+	for i, code := range codes {
+		card, err := t.parseCode(code)
+		if err != nil {
+			errs = append(errs, &CardError{Code: code, Position: i, Err: err})
+			continue
+		}
+		cards[i] = card
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return cards, nil
+}
+
+// The card the code, or its alternative notation, is in a deck of this type, or why it isn't one.
+func (t *DeckType) parseCode(code string) (Card, error) {
+	// A code that is already a card is taken as it is, so that deck types with lower case codes keep them.
+	err := t.checkCode(code)
+	if err == nil || err == ErrEmptyCardCode {
+		return Card(code), err
+	}
+
+	normal := t.normalizeCode(code)
+	if err := t.checkCode(normal); err != nil {
+		return "", err
+	}
+	return Card(normal), nil
+}
+
+// The Unicode suite symbols, black and white, and the names of the suites they stand for.
+var suiteSymbols = map[rune]string{
+	'♠': "SPADES", '♤': "SPADES",
+	'♥': "HEARTS", '♡': "HEARTS",
+	'♦': "DIAMONDS", '♢': "DIAMONDS",
+	'♣': "CLUBS", '♧': "CLUBS",
+}
+
+// Ask for a character to be shown as an emoji, as phones often add after a suite symbol.
+const emojiVariation = '\uFE0F'
+
+// Rewrite the alternative notations of a code in the notation of this deck type.  The result may still not be a card.
+func (t *DeckType) normalizeCode(code string) string {
+	code = strings.ToUpper(code)
+	code = strings.TrimSuffix(code, string(emojiVariation))
+
+	if symbol, size := utf8.DecodeLastRuneInString(code); size > 0 {
+		if name, ok := suiteSymbols[symbol]; ok {
+			for suite, suiteName := range t.Suites {
+				if suiteName == name {
+					code = code[:len(code)-size] + suite
+					break
+				}
+			}
+		}
+	}
+
+	if len(code) == 2 && (code[0] == 'T' || code[0] == '0') {
+		if _, isRank := t.Ranks[code[:1]]; !isRank {
+			if _, hasTen := t.Ranks["10"]; hasTen {
+				code = "10" + code[1:]
+			}
+		}
+	}
+
+	return code
+}
+
+// Why the code isn't a card in a deck of this type, or nil if it is.
+func (t *DeckType) checkCode(code string) error {
+	if code == "" {
+		return ErrEmptyCardCode
+	}
+
+	// The code is compared whole, rather than as a Card, so that nothing can be slipped in after it.
+	for _, cards := range []string{t.Cards, t.Jokers} {
+		for _, included := range strings.Fields(cards) {
+			if included == code {
+				return nil
+			}
+		}
+	}
+
+	if _, ok := t.Specials[code]; ok {
+		// A special card the deck type names, but doesn't include, such as a joker of a type without them.
+		return ErrCardNotInDeck
+	}
+
+	rank, suite := code[:len(code)-1], code[len(code)-1:]
+	_, rankOk := t.ranksOf(suite)[rank]
+	_, suiteOk := t.Suites[suite]
+
+	switch {
+	case !rankOk && !suiteOk:
+		return ErrUnknownCard
+	case !rankOk:
+		return ErrUnknownRank
+	case !suiteOk:
+		return ErrUnknownSuite
+	default:
+		return ErrCardNotInDeck
+	}
+}
+
+// The standard deck type.
+func standardDeckType() *DeckType {
+	t, _ := LookupDeckType(StandardDeckTypeName)
+	return t
+}
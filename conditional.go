@@ -0,0 +1,91 @@
+/*
+	Conditional requests on decks.
+
+	Every change to a deck bumps its version, which is sent as the ETag of the deck.  A client that wants to change a
+	deck only if nobody else has since it last looked sends the ETag it saw as If-Match; if the deck has changed in the
+	meantime, the request fails with 412 Precondition Failed and the deck is left alone.  A client that already has the
+	latest details of a deck can send their ETag as If-None-Match when opening it, and is answered 304 Not Modified.
+*/
+
+package toggleDecks
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// The ETag of the deck as it is now.
+func (d *Deck) ETag() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return versionETag(d.Version)
+}
+
+// The ETag of a version of a deck: the version, quoted.
+func versionETag(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// Does the If-Match or If-None-Match header match the ETag?  A "*" matches any ETag.  Weak ETags match only if weak
+// is true, as If-None-Match allows but If-Match doesn't.
+func etagMatches(header string, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// Start changing a deck for a request, holding off every other change to it until done is called.  The deck is
+// remembered as it is, so that App.updateDeck can undo the change if it can't be stored.  If the request's If-Match
+// doesn't match the deck's ETag, write an error and return ok=false, without holding anything.
+// This is meant to be called as a helper from REST endpoints, it is not an endpoint itself.
+func beginDeckUpdate(w http.ResponseWriter, r *http.Request, deck *Deck) (done func(), ok bool) {
+	deck.update.Lock()
+
+	if !checkIfMatch(w, r, deck) {
+		deck.update.Unlock()
+		return nil, false
+	}
+
+	return startDeckUpdate(deck), true
+}
+
+// Remember the deck as it is, for the change about to be made under the update lock, which must be held.  Returns the
+// function finishing the change.
+func startDeckUpdate(deck *Deck) (done func()) {
+	deck.undo = deck.saveState()
+	return func() {
+		deck.undo = nil
+		deck.update.Unlock()
+	}
+}
+
+// Check the request's If-Match, if it has one, against the deck's ETag.  If it doesn't match, write an error and
+// return false.
+func checkIfMatch(w http.ResponseWriter, r *http.Request, deck *Deck) bool {
+	if ifMatch := r.Header.Get("If-Match"); len(ifMatch) != 0 && !etagMatches(ifMatch, deck.ETag(), false) {
+		WriteError(w, http.StatusPreconditionFailed, ErrorVersionMismatch, fmt.Sprintf("The deck has changed: it is now version %v.", deck.ETag()))
+		return false
+	}
+	return true
+}
+
+// Send the deck's ETag with the response.
+func setETag(w http.ResponseWriter, deck *Deck) {
+	w.Header().Set("ETag", deck.ETag())
+}
+
+// Indicate that the client already has what it asked for.
+func WriteNotModified(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusNotModified)
+}
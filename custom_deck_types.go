@@ -0,0 +1,189 @@
+/*
+	Deck types defined by users, uploaded as JSON.
+
+	A definition lists the type's suites, ranks and special cards, each as a code and the name it is shown with.  A deck
+	of the type has one of every rank in every suite, suite by suite in the order given, followed by one of every special
+	card, unless counts says otherwise: it maps card codes to how many of that card there are, and a count of zero
+	leaves the card out altogether.  Metadata is kept with the type and handed back when it is listed, but is otherwise
+	up to the designer.
+
+	Defined types are kept in the app's store, and belong to that app alone: another app only sees them if it shares the
+	store.
+
+		{
+			"name": "zoo",
+			"description": "Animals in cages.",
+			"suites": [{"code": "L", "name": "LIONS"}, {"code": "T", "name": "TIGERS"}],
+			"ranks": [{"code": "1", "name": "CUB"}, {"code": "2", "name": "ADULT"}],
+			"specials": [{"code": "ZK", "name": "ZOOKEEPER"}],
+			"counts": {"1L": 3, "2T": 0},
+			"metadata": {"designer": "Jo"}
+		}
+*/
+
+package toggleDecks
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// The most cards a deck of a user defined type can have.
+const MaxDeckTypeSize = 1000
+
+// Deck type names are lower case letters, digits and dashes.
+var deckTypeNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,63}$`)
+
+// Card codes are letters and digits.
+var cardCodePattern = regexp.MustCompile(`^[A-Za-z0-9]+$`)
+
+// A code used in cards, and the name it is shown with.
+type NamedCode struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+// The JSON definition of a user defined deck type.
+type DeckTypeDefinition struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Suites      []NamedCode            `json:"suites"`
+	Ranks       []NamedCode            `json:"ranks"`
+	Specials    []NamedCode            `json:"specials"`
+	Counts      map[string]int         `json:"counts"`
+	Metadata    map[string]interface{} `json:"metadata"`
+}
+
+// Check the definition and build the deck type it defines.
+func (def DeckTypeDefinition) Build() (*DeckType, error) {
+	if !deckTypeNamePattern.MatchString(def.Name) {
+		return nil, fmt.Errorf("deck type names must be 1 to 64 lower case letters, digits and dashes, not %q", def.Name)
+	}
+
+	suites, err := namedCodes("suite", def.Suites, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	ranks, err := namedCodes("rank", def.Ranks, 1, 4)
+	if err != nil {
+		return nil, err
+	}
+
+	specials, err := namedCodes("special card", def.Specials, 1, 8)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(suites) > 0 && len(ranks) == 0 || len(ranks) > 0 && len(suites) == 0 {
+		return nil, fmt.Errorf("deck types need both suites and ranks, or neither")
+	}
+
+	// Every card the type can have, in order.
+	var codes []string
+	for _, suite := range def.Suites {
+		for _, rank := range def.Ranks {
+			codes = append(codes, rank.Code+suite.Code)
+		}
+	}
+
+	known := map[string]bool{}
+	for _, code := range codes {
+		known[code] = true
+	}
+
+	for _, special := range def.Specials {
+		if known[special.Code] {
+			return nil, fmt.Errorf("special card %v has the same code as a suited card", special.Code)
+		}
+		codes = append(codes, special.Code)
+		known[special.Code] = true
+	}
+
+	for code, count := range def.Counts {
+		if !known[code] {
+			return nil, fmt.Errorf("%v in counts is not a card of this deck type", code)
+		}
+		if count < 0 {
+			return nil, fmt.Errorf("%v can't have a negative count", code)
+		}
+	}
+
+	var cards []string
+	for _, code := range codes {
+		count, ok := def.Counts[code]
+		if !ok {
+			count = 1
+		}
+
+		if len(cards)+count > MaxDeckTypeSize {
+			return nil, fmt.Errorf("deck types can have at most %v cards", MaxDeckTypeSize)
+		}
+		for i := 0; i < count; i++ {
+			cards = append(cards, code)
+		}
+	}
+
+	if len(cards) == 0 {
+		return nil, fmt.Errorf("deck types must have at least one card")
+	}
+
+	return &DeckType{
+		Name:        def.Name,
+		Description: def.Description,
+		Cards:       strings.Join(cards, " "),
+		Suites:      suites,
+		Ranks:       ranks,
+		Specials:    specials,
+		Metadata:    def.Metadata,
+		UserDefined: true,
+	}, nil
+}
+
+// Check a list of codes and their names, and map the codes to the names.  Each code must be unique, and between
+// minLength and maxLength letters and digits long.
+func namedCodes(kind string, list []NamedCode, minLength int, maxLength int) (map[string]string, error) {
+	names := map[string]string{}
+	for _, nc := range list {
+		if len(nc.Code) < minLength || len(nc.Code) > maxLength || !cardCodePattern.MatchString(nc.Code) {
+			return nil, fmt.Errorf("%v code %q must be %v to %v letters and digits", kind, nc.Code, minLength, maxLength)
+		}
+
+		if len(nc.Name) == 0 || len(nc.Name) > 64 {
+			return nil, fmt.Errorf("%v %v must have a name of at most 64 characters", kind, nc.Code)
+		}
+
+		if _, ok := names[nc.Code]; ok {
+			return nil, fmt.Errorf("%v code %v is used more than once", kind, nc.Code)
+		}
+		names[nc.Code] = nc.Name
+	}
+	return names, nil
+}
+
+// Find the deck type with the passed name, whether built in or defined by this app's users.  An empty name is the
+// standard deck type.
+func (a *App) LookupDeckType(name string) (t *DeckType, ok bool) {
+	if t, ok = LookupDeckType(name); ok {
+		return
+	}
+	return a.deckTypes.lookup(name)
+}
+
+// All the deck types this app can create decks of, in order of name.
+func (a *App) DeckTypes() []*DeckType {
+	return sortDeckTypes(append(DeckTypes(), a.deckTypes.list()...))
+}
+
+// Add a user defined deck type to the ones this app knows, unless there is already a deck type by that name.
+func (a *App) addDeckType(t *DeckType) error {
+	if _, ok := LookupDeckType(t.Name); ok {
+		return ErrDeckTypeExists
+	}
+
+	if !a.deckTypes.add(t, false) {
+		return ErrDeckTypeExists
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+/*
+	Labels and metadata kept with a deck.
+
+	Labels are short strings, by name, meant for finding decks again: a table id, a game, a player.  Metadata is any JSON
+	the creator wants kept with the deck.  Both can be given when the deck is created, and changed later by patching
+	them: each name patched is set to its new value, or removed if the value is null, and the rest are left alone.
+*/
+
+package toggleDecks
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+// The most labels a deck can have.
+const MaxLabels = 64
+
+// The longest a label's value can be, in bytes.
+const MaxLabelValueLength = 256
+
+// The largest a deck's metadata can be, in bytes of JSON.
+const MaxMetadataSize = 16 * 1024
+
+// Label names are 1 to 63 letters, digits, dots, dashes and underscores, starting with a letter or digit.
+var labelNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,62}$`)
+
+// Check that labels are within the limits on them.
+func ValidateLabels(labels map[string]string) error {
+	if len(labels) > MaxLabels {
+		return fmt.Errorf("decks can have at most %v labels", MaxLabels)
+	}
+
+	for name, value := range labels {
+		if !labelNamePattern.MatchString(name) {
+			return fmt.Errorf("label names must be 1 to 63 letters, digits, dots, dashes and underscores, not %q", name)
+		}
+		if len(value) > MaxLabelValueLength {
+			return fmt.Errorf("label %v is longer than %v bytes", name, MaxLabelValueLength)
+		}
+	}
+
+	return nil
+}
+
+// Check that metadata is within the limit on its size.
+func ValidateMetadata(metadata map[string]interface{}) error {
+	encoded, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+
+	if len(encoded) > MaxMetadataSize {
+		return fmt.Errorf("metadata is larger than %v bytes", MaxMetadataSize)
+	}
+	return nil
+}
+
+// Change the deck's labels and metadata.  Each name in the patches is set to its new value, or removed if the value is
+// nil.  If the result would be beyond the limits, nothing is changed and the error says why.
+func (d *Deck) Patch(labels map[string]*string, metadata map[string]interface{}) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// The maps are replaced rather than changed, so that copies handed out to be sent back are never written to.
+	patchedLabels := make(map[string]string, len(d.Labels))
+	for name, value := range d.Labels {
+		patchedLabels[name] = value
+	}
+	for name, value := range labels {
+		if value == nil {
+			delete(patchedLabels, name)
+		} else {
+			patchedLabels[name] = *value
+		}
+	}
+
+	patchedMetadata := make(map[string]interface{}, len(d.Metadata))
+	for name, value := range d.Metadata {
+		patchedMetadata[name] = value
+	}
+	for name, value := range metadata {
+		if value == nil {
+			delete(patchedMetadata, name)
+		} else {
+			patchedMetadata[name] = value
+		}
+	}
+
+	if err := ValidateLabels(patchedLabels); err != nil {
+		return err
+	}
+	if err := ValidateMetadata(patchedMetadata); err != nil {
+		return err
+	}
+
+	d.Labels, d.Metadata = nil, nil
+	if len(patchedLabels) != 0 {
+		d.Labels = patchedLabels
+	}
+	if len(patchedMetadata) != 0 {
+		d.Metadata = patchedMetadata
+	}
+	d.Version++
+	return nil
+}
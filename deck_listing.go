@@ -0,0 +1,189 @@
+/*
+	Listing the decks in the store a page at a time.
+
+	Decks are listed oldest first, by the time they were created, with ties broken by ID, so the order doesn't change as
+	decks come and go.  Each page ends with a cursor naming the last deck on it; the next page starts after that deck,
+	so decks created or deleted between pages don't cause any to be skipped or listed twice.
+
+	Decks can be filtered by whether they are shuffled, whether they are empty, when they were created, by their labels,
+	and by their owner and tags, which are kept in the deck's metadata as "owner" (a string) and "tags" (a list of
+	strings).
+*/
+
+package toggleDecks
+
+import (
+	"encoding/base64"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The number of decks listed on a page when no limit is asked for.
+const DefaultDeckPageSize = 100
+
+// The most decks that can be listed on one page.
+const MaxDeckPageSize = 1000
+
+// Returned when a cursor can't be decoded.
+var ErrBadCursor = errors.New("invalid cursor")
+
+// Which decks to list.  Unset fields don't filter.
+type DeckFilter struct {
+	Shuffled     *bool
+	Empty        *bool
+	CreatedAfter *time.Time
+	Owner        string
+
+	// Decks must have every one of these tags.
+	Tags []string
+
+	// Decks must have every one of these labels, with the same value.
+	Labels map[string]string
+}
+
+// A deck as it is when listed.
+type DeckListing struct {
+	Id      string
+	Deck    *Deck
+	Created time.Time
+}
+
+// Does the deck pass the filter?
+func (f DeckFilter) Matches(deck *Deck) bool {
+	deck.mu.Lock()
+	defer deck.mu.Unlock()
+
+	if f.Shuffled != nil && deck.Shuffled != *f.Shuffled {
+		return false
+	}
+
+	if f.Empty != nil && (len(deck.Cards) == 0) != *f.Empty {
+		return false
+	}
+
+	if f.CreatedAfter != nil && !deck.Created.After(*f.CreatedAfter) {
+		return false
+	}
+
+	if len(f.Owner) != 0 {
+		if owner, _ := deck.Metadata["owner"].(string); owner != f.Owner {
+			return false
+		}
+	}
+
+	for name, value := range f.Labels {
+		if labelled, ok := deck.Labels[name]; !ok || labelled != value {
+			return false
+		}
+	}
+
+	for _, tag := range f.Tags {
+		if !hasTag(deck.Metadata, tag) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Is the tag one of the "tags" in the metadata?
+func hasTag(metadata map[string]interface{}, tag string) bool {
+	tags, _ := metadata["tags"].([]interface{})
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// The position in the listing order of a deck: the time it was created, and its ID.
+type deckCursor struct {
+	created time.Time
+	id      string
+}
+
+// Is the deck at this position listed before the other?
+func (c deckCursor) before(other deckCursor) bool {
+	if !c.created.Equal(other.created) {
+		return c.created.Before(other.created)
+	}
+	return c.id < other.id
+}
+
+// Encode the cursor as the opaque string handed to clients.
+func (c deckCursor) String() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(c.created.UnixNano(), 10) + ":" + c.id))
+}
+
+// Decode a cursor handed to a client.
+func parseDeckCursor(s string) (c deckCursor, err error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrBadCursor
+	}
+
+	nanos, id, ok := strings.Cut(string(decoded), ":")
+	if !ok {
+		return c, ErrBadCursor
+	}
+
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return c, ErrBadCursor
+	}
+
+	return deckCursor{time.Unix(0, n), id}, nil
+}
+
+// List a page of the decks that pass the filter, starting after the cursor (or from the first deck if the cursor is
+// empty).  The next cursor is empty if this is the last page.  Expired decks are not listed.
+func (a *App) ListDecks(filter DeckFilter, cursor string, limit int) (page []DeckListing, next string, err error) {
+	var after *deckCursor
+	if len(cursor) != 0 {
+		c, err := parseDeckCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		after = &c
+	}
+
+	now := TheClock.Now()
+	var listings []DeckListing
+	for _, iid := range a.Store.List() {
+		deck, ok := a.GetDeck(iid)
+		if !ok || (a.IdleTTL > 0 && deck.Expired(a.IdleTTL, now)) || !filter.Matches(deck) {
+			continue
+		}
+
+		listing := DeckListing{Id: iid, Deck: deck, Created: deck.createdAt()}
+		if after != nil && !after.before(listing.position()) {
+			continue
+		}
+		listings = append(listings, listing)
+	}
+
+	sort.Slice(listings, func(i, j int) bool { return listings[i].position().before(listings[j].position()) })
+
+	if len(listings) > limit {
+		listings = listings[:limit]
+		next = listings[limit-1].position().String()
+	}
+	return listings, next, nil
+}
+
+// Where the listed deck is in the listing order.
+func (l DeckListing) position() deckCursor {
+	return deckCursor{l.Created, l.Id}
+}
+
+// When the deck was created.
+func (d *Deck) createdAt() time.Time {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.Created
+}
@@ -2,10 +2,11 @@ package toggleDecks
 
 import (
 	"encoding/json"
-	"fmt"
 	"github.com/google/uuid"
 	"log"
 	"net/http"
+	"strings"
+	"time"
 )
 
 /*
@@ -31,35 +32,105 @@ var TheGuidProvider RestIdProvider = GuidIdProvider{}
 // The object used to list decks.
 type ListDeckMessage struct {
 	Decks []RestDeckMessage `json:"decks"`
+
+	// Where the next page of decks starts.  Empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// The object describing a deck to create, sent as the JSON body of a create request.  The fields are the same as the
+// query string parameters, except that the cards are a list, the labels are an object, and metadata can only be given
+// this way.
+type RestCreateDeckRequest struct {
+	Cards         []string               `json:"cards"`
+	Shuffle       bool                   `json:"shuffle"`
+	Type          string                 `json:"type"`
+	Seed          *int64                 `json:"seed"`
+	ShuffleMode   string                 `json:"shuffle_mode"`
+	DeckCount     *int                   `json:"deck_count"`
+	JokersEnabled bool                   `json:"jokers_enabled"`
+	ClientSeed    string                 `json:"client_seed"`
+	Labels        map[string]string      `json:"labels"`
+	Metadata      map[string]interface{} `json:"metadata"`
+}
+
+// The object describing changes to a deck's labels and metadata, sent as the JSON body of a patch request.  Names given
+// a null value are removed.
+type RestPatchDeckRequest struct {
+	Labels   map[string]*string     `json:"labels"`
+	Metadata map[string]interface{} `json:"metadata"`
 }
 
 // The object representing the deck information.  This is used both when we are and are not returning the cards in the deck.
 type RestDeckMessage struct {
-	Id        string     `json:"deck_id"`
-	Shuffled  *bool      `json:"shuffled,omitempty"`
-	Remaining *int       `json:"remaining,omitempty"`
-	Cards     []RestCard `json:"cards,omitempty"`
+	Id          string           `json:"deck_id"`
+	Shuffled    *bool            `json:"shuffled,omitempty"`
+	Remaining   *int             `json:"remaining,omitempty"`
+	Version     *int64           `json:"version,omitempty"`
+	Seed        *int64           `json:"seed,omitempty"`
+	ShuffleMode string           `json:"shuffle_mode,omitempty"`
+	Type        string           `json:"type,omitempty"`
+	Fair        *RestFairMessage `json:"fair,omitempty"`
+	Cards       []RestCard       `json:"cards,omitempty"`
+	Drawn       []RestCard       `json:"drawn,omitempty"`
+	ExpiresAt   *time.Time       `json:"expires_at,omitempty"`
+	Created     *time.Time       `json:"created,omitempty"`
+
+	Labels   map[string]string      `json:"labels,omitempty"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
 }
 
 // Create a new RestDockMessage from the iid and *Deck.  It can include or exclude the actual cards.
 func NewRestDeckMessage(iid string, deck *Deck, includeCards bool) (rdm RestDeckMessage) {
-	remaining := deck.Len()
+	deck.mu.Lock()
+	defer deck.mu.Unlock()
+
+	remaining := len(deck.Cards)
 	shuffled := deck.Shuffled
+	version := deck.Version
+
+	// The seed is only meaningful once the deck has been shuffled with it, and only seeded decks have one.
+	var seed *int64
+	if shuffled && deck.ShuffleMode == ShuffleSeeded {
+		s := deck.Seed
+		seed = &s
+	}
+
+	var fair *RestFairMessage
+	if deck.Fair != nil {
+		fair = &RestFairMessage{ServerSeedHash: deck.Fair.ServerSeedHash, ClientSeed: deck.Fair.ClientSeed, Commitment: deck.Fair.Commitment}
+		if deck.Fair.Revealed {
+			fair.ServerSeed = deck.Fair.ServerSeed
+		}
+	}
 
 	var cards []RestCard
 	if includeCards {
-		cards = NewRestDrawMessage(deck.Cards).Cards
+		cards = NewRestDrawMessage(deck.DeckType(), deck.Cards).Cards
 	} else {
 		cards = []RestCard{}
 	}
-	return RestDeckMessage{iid, &shuffled, &remaining, cards}
+	return RestDeckMessage{Id: iid, Shuffled: &shuffled, Remaining: &remaining, Version: &version, Seed: seed, ShuffleMode: string(deck.ShuffleMode), Type: deck.Type, Fair: fair, Cards: cards, Labels: deck.Labels, Metadata: deck.Metadata}
 }
 
-// Structure for JSON serialization of a Card.
+// The published part of a fair deck's shuffle.  The server seed is only included once it has been revealed.
+type RestFairMessage struct {
+	ServerSeedHash string `json:"server_seed_hash"`
+	ClientSeed     string `json:"client_seed,omitempty"`
+	Commitment     string `json:"commitment,omitempty"`
+	ServerSeed     string `json:"server_seed,omitempty"`
+}
+
+// Structure for JSON serialization of a Card.  Jokers have no suite.
 type RestCard struct {
 	Value string `json:"value"`
-	Suite string `json:"suite"`
+	Suite string `json:"suite,omitempty"`
 	Code  string `json:"code"`
+
+	// The number of the deck in a shoe that the card came from.  Only given when asked for.
+	Source int `json:"source,omitempty"`
+
+	// The Unicode playing card glyph for the card.  Only given when asked for, and only for cards that have one.
+	Glyph string `json:"glyph,omitempty"`
 }
 
 // The object representing the draw of a number of cards.
@@ -67,15 +138,125 @@ type RestDrawMessage struct {
 	Cards []RestCard `json:"cards"`
 }
 
-// Return a RestDrawMessage from  an array of Card structs.  Translation for JSON serialization.
-func NewRestDrawMessage(cards []Card) RestDrawMessage {
+// Return a RestDrawMessage from  an array of Card structs, named as in a deck of the passed type.  Translation for JSON
+// serialization.
+func NewRestDrawMessage(deckType *DeckType, cards []Card) RestDrawMessage {
 	restCards := make([]RestCard, len(cards))
 	for i, c := range cards {
-		restCards[i] = RestCard{c.Rank(), c.Suite(), c.Code()}
+		restCards[i] = RestCard{Value: deckType.Rank(c), Suite: deckType.Suite(c), Code: c.Code()}
 	}
 	return RestDrawMessage{restCards}
 }
 
+// The object representing one entry in the history of a deck.
+type RestDeckEvent struct {
+	Time     time.Time  `json:"time"`
+	Action   string     `json:"action"`
+	Count    int        `json:"count"`
+	Cards    []RestCard `json:"cards"`
+	Position string     `json:"position,omitempty"`
+	From     string     `json:"from,omitempty"`
+	Pile     string     `json:"pile,omitempty"`
+}
+
+// The object representing the history of a deck: what it started with and what has happened to it since.
+type RestHistoryMessage struct {
+	Id       string          `json:"deck_id"`
+	Original []RestCard      `json:"original"`
+	History  []RestDeckEvent `json:"history"`
+}
+
+// Create a new RestHistoryMessage from the iid and *Deck.  It can include or exclude which deck of a shoe each card came
+// from.
+func NewRestHistoryMessage(iid string, deck *Deck, includeSources bool) RestHistoryMessage {
+	original, history := deck.GetHistory()
+
+	restCards := func(cards []Card) []RestCard {
+		restCards := NewRestDrawMessage(deck.DeckType(), cards).Cards
+		if includeSources {
+			for i, c := range cards {
+				restCards[i].Source = c.Source()
+			}
+		}
+		return restCards
+	}
+
+	events := make([]RestDeckEvent, len(history))
+	for i, e := range history {
+		events[i] = RestDeckEvent{e.Time, e.Action, e.Count, restCards(e.Cards), string(e.Position), e.From, e.Pile}
+	}
+
+	return RestHistoryMessage{iid, restCards(original), events}
+}
+
+// The object representing a pile, with the cards in it.
+type RestPileMessage struct {
+	Id        string     `json:"deck_id"`
+	Pile      string     `json:"pile"`
+	Remaining int        `json:"remaining"`
+	Cards     []RestCard `json:"cards"`
+}
+
+// Create a new RestPileMessage from the iid, pile name and the cards in the pile, named as in a deck of the passed type.
+func NewRestPileMessage(iid string, name string, deckType *DeckType, cards []Card) RestPileMessage {
+	return RestPileMessage{iid, name, len(cards), NewRestDrawMessage(deckType, cards).Cards}
+}
+
+// The object summarizing a pile when listing them.
+type RestPileSummary struct {
+	Pile      string `json:"pile"`
+	Remaining int    `json:"remaining"`
+}
+
+// The object used to list the piles of a deck.
+type RestPileListMessage struct {
+	Id    string            `json:"deck_id"`
+	Piles []RestPileSummary `json:"piles"`
+}
+
+// Create a new RestPileListMessage from the iid and *Deck.
+func NewRestPileListMessage(iid string, deck *Deck) RestPileListMessage {
+	names, sizes := deck.PileSizes()
+	piles := make([]RestPileSummary, len(names))
+	for i := range names {
+		piles[i] = RestPileSummary{names[i], sizes[i]}
+	}
+	return RestPileListMessage{iid, piles}
+}
+
+// The object describing a deck type.
+type RestDeckTypeMessage struct {
+	Name        string                       `json:"name"`
+	Description string                       `json:"description"`
+	Size        int                          `json:"size"`
+	Cards       []RestCard                   `json:"cards"`
+	Suites      map[string]string            `json:"suites"`
+	Ranks       map[string]string            `json:"ranks"`
+	SuiteRanks  map[string]map[string]string `json:"suite_ranks,omitempty"`
+	Jokers      []RestCard                   `json:"jokers,omitempty"`
+	Metadata    map[string]interface{}       `json:"metadata,omitempty"`
+	UserDefined bool                         `json:"user_defined,omitempty"`
+}
+
+// The object used to list the deck types.
+type RestDeckTypeListMessage struct {
+	DeckTypes []RestDeckTypeMessage `json:"deck_types"`
+}
+
+// Create a new RestDeckTypeMessage from a *DeckType.
+func NewRestDeckTypeMessage(t *DeckType) RestDeckTypeMessage {
+	cards := func(codes string) []RestCard {
+		fields := strings.Fields(codes)
+		cards := make([]Card, len(fields))
+		for i, code := range fields {
+			cards[i] = Card(code)
+		}
+		return NewRestDrawMessage(t, cards).Cards
+	}
+
+	return RestDeckTypeMessage{t.Name, t.Description, t.Size(), cards(t.Cards), t.Suites, t.Ranks, t.SuiteRanks, cards(t.Jokers), t.Metadata, t.UserDefined}
+}
+
 // Indicate success and write json data.
 func WriteSuccess(w http.ResponseWriter, rm interface{}) {
 	w.Header().Set("Content-Type", "application/json")
@@ -85,9 +266,7 @@ func WriteSuccess(w http.ResponseWriter, rm interface{}) {
 	}
 }
 
-// Indicate a error and write an error message.
-func WriteError(w http.ResponseWriter, errorCode int, message string) {
-	w.WriteHeader(errorCode)
-	_, _ = fmt.Fprint(w, message)
-
+// Indicate success when there is nothing to send back.
+func WriteNoContent(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusNoContent)
 }
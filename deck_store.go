@@ -0,0 +1,161 @@
+package toggleDecks
+
+import (
+	"errors"
+	"sync"
+)
+
+/*
+	Storage for decks.  The App talks to its decks only through the DeckStore interface so that the storage can be
+	swapped out (persistent, instrumented, etc.) without touching the REST handlers.
+
+	User defined deck types are kept in the store too, since decks of those types can't be used without them, if the
+	store also implements the DeckTypeStore interface.  Stores that don't can still be used, but no deck types can be
+	defined with them.
+*/
+
+// Returned by a store when asked about a deck ID it does not hold.
+var ErrDeckNotFound = errors.New("deck not found")
+
+// Returned by a store when asked to create a deck under an ID that is already in use.
+var ErrDeckExists = errors.New("deck already exists")
+
+// Returned by a store when asked to file a deck type under a name that is already in use.
+var ErrDeckTypeExists = errors.New("deck type already exists")
+
+// Interface to the storage used for decks.  Implementations must be safe for concurrent use by multiple requests.
+type DeckStore interface {
+	// File a new deck under the passed ID.
+	Create(iid string, deck *Deck) error
+
+	// Fetch a deck by its ID.
+	Get(iid string) (deck *Deck, ok bool)
+
+	// Record that a deck has been changed (e.g. cards were drawn from it).
+	Update(iid string, deck *Deck) error
+
+	// Remove a deck from the store.
+	Delete(iid string) error
+
+	// The IDs of every deck in the store, in no particular order.
+	List() []string
+
+	// Remove every deck from the store.  Deck types are kept.
+	Clear() error
+}
+
+// Interface to the storage used for user defined deck types, optionally implemented by a DeckStore.  Implementations
+// must be safe for concurrent use by multiple requests.
+type DeckTypeStore interface {
+	// File a user defined deck type.  Deck types can't be changed or removed once filed.
+	CreateDeckType(t *DeckType) error
+
+	// Every user defined deck type in the store, in no particular order.
+	ListDeckTypes() []*DeckType
+}
+
+// The default in memory DeckStore.  Decks only live as long as the process does.
+type MemoryDeckStore struct {
+	mu    sync.RWMutex
+	decks map[string]*Deck
+	types map[string]*DeckType
+}
+
+// Create a new, empty, in memory deck store.
+func NewMemoryDeckStore() *MemoryDeckStore {
+	return &MemoryDeckStore{decks: map[string]*Deck{}, types: map[string]*DeckType{}}
+}
+
+// Implement the DeckStore interface
+func (s *MemoryDeckStore) Create(iid string, deck *Deck) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.decks[iid]; ok {
+		return ErrDeckExists
+	}
+
+	s.decks[iid] = deck
+	return nil
+}
+
+// Implement the DeckStore interface
+func (s *MemoryDeckStore) Get(iid string) (deck *Deck, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	deck, ok = s.decks[iid]
+	return
+}
+
+// Implement the DeckStore interface.  The deck is held by pointer, so there is nothing to write back.
+func (s *MemoryDeckStore) Update(iid string, deck *Deck) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.decks[iid]; !ok {
+		return ErrDeckNotFound
+	}
+
+	s.decks[iid] = deck
+	return nil
+}
+
+// Implement the DeckStore interface
+func (s *MemoryDeckStore) Delete(iid string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.decks[iid]; !ok {
+		return ErrDeckNotFound
+	}
+
+	delete(s.decks, iid)
+	return nil
+}
+
+// Implement the DeckStore interface
+func (s *MemoryDeckStore) List() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := make([]string, 0, len(s.decks))
+	for id := range s.decks {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Implement the DeckStore interface
+func (s *MemoryDeckStore) Clear() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.decks = map[string]*Deck{}
+	return nil
+}
+
+// Implement the DeckTypeStore interface
+func (s *MemoryDeckStore) CreateDeckType(t *DeckType) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.types[t.Name]; ok {
+		return ErrDeckTypeExists
+	}
+
+	s.types[t.Name] = t
+	return nil
+}
+
+// Implement the DeckTypeStore interface
+func (s *MemoryDeckStore) ListDeckTypes() []*DeckType {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	types := make([]*DeckType, 0, len(s.types))
+	for _, t := range s.types {
+		types = append(types, t)
+	}
+	return types
+}
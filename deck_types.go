@@ -0,0 +1,347 @@
+/*
+	The kinds of deck that can be created.
+
+	A deck type is the cards a deck of that kind is made of, in the order a new deck has them, and the vocabulary that
+	names them.  As with the standard deck, a card's code is its rank's code followed by its suite's code, unless it is
+	one of the type's special cards (such as a joker, or the Excuse in Tarot), which are named by their whole code.
+*/
+
+package toggleDecks
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Returned when asked for a deck type that doesn't exist.
+var ErrUnknownDeckType = errors.New("unknown deck type")
+
+// Returned when asked to add jokers to a deck type that doesn't have any.
+var ErrNoJokers = errors.New("deck type has no jokers")
+
+// The name of the deck type decks are when no type is asked for: the French 52 card deck.
+const StandardDeckTypeName = "standard"
+
+// A kind of deck.
+type DeckType struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+
+	// Space separated codes of the cards in a deck of this type, in the order a new deck has them.
+	Cards string `json:"cards"`
+
+	// Map the codes of the suites, ranks and special cards to their full names.
+	Suites   map[string]string `json:"suites"`
+	Ranks    map[string]string `json:"ranks"`
+	Specials map[string]string `json:"specials,omitempty"`
+
+	// The ranks of suites that have their own, such as the Tarot trumps, by suite code.  Cards of those suites are named
+	// by these rather than by Ranks.
+	SuiteRanks map[string]map[string]string `json:"suite_ranks,omitempty"`
+
+	// Space separated codes of the jokers added to the deck when jokers are asked for.  Empty if there are none.
+	Jokers string `json:"jokers,omitempty"`
+
+	// Are the cards shown with their Unicode playing card glyphs?  Only for deck types whose cards are the French or
+	// Tarot cards the glyphs are of.
+	Glyphs bool `json:"glyphs,omitempty"`
+
+	// Anything the designer of a user defined deck type wants kept with it.
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+
+	// Was the deck type defined by a user, rather than built in?
+	UserDefined bool `json:"user_defined,omitempty"`
+}
+
+// The number of cards in a deck of this type, without jokers.
+func (t *DeckType) Size() int {
+	return len(strings.Fields(t.Cards))
+}
+
+// The full name of the card's rank, or of the card itself if it is a special card.
+func (t *DeckType) Rank(c Card) string {
+	code := c.Code()
+	if name, ok := t.Specials[code]; ok {
+		return name
+	}
+
+	if len(code) < 2 {
+		return ""
+	}
+	return t.ranksOf(code[len(code)-1:])[code[:len(code)-1]]
+}
+
+// The ranks of the suite with the passed code.
+func (t *DeckType) ranksOf(suite string) map[string]string {
+	if ranks, ok := t.SuiteRanks[suite]; ok {
+		return ranks
+	}
+	return t.Ranks
+}
+
+// The full name of the card's suite.  Empty for special cards, which don't have one.
+func (t *DeckType) Suite(c Card) string {
+	code := c.Code()
+	if _, ok := t.Specials[code]; ok || len(code) < 2 {
+		return ""
+	}
+	return t.Suites[code[len(code)-1:]]
+}
+
+// Is the card one that can be in a deck of this type, including its jokers?
+func (t *DeckType) Contains(c Card) bool {
+	code := c.Code()
+	for _, cards := range []string{t.Cards, t.Jokers} {
+		for _, included := range strings.Fields(cards) {
+			if included == code {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// The first code point of each row of the Unicode playing cards block, by the name of the suite the row is for.
+var glyphSuites = map[string]rune{"SPADES": 0x1F0A0, "HEARTS": 0x1F0B0, "DIAMONDS": 0x1F0C0, "CLUBS": 0x1F0D0, "TRUMPS": 0x1F0E0}
+
+// Where each card is in its row of the Unicode playing cards block, by the name of its rank.  Trumps are by number.
+var glyphRanks = map[string]rune{"ACE": 1, "JACK": 11, "KNIGHT": 12, "QUEEN": 13, "KING": 14}
+
+// The glyphs of the special cards that have one, by name.
+var glyphSpecials = map[string]rune{"RED JOKER": 0x1F0BF, "BLACK JOKER": 0x1F0CF, "THE EXCUSE": 0x1F0E0}
+
+// The Unicode playing card glyph for the card, or empty if there isn't one, or the deck type doesn't show glyphs.
+// Glyphs are found by the names of the card's rank and suite.
+func (t *DeckType) Glyph(c Card) string {
+	if !t.Glyphs {
+		return ""
+	}
+
+	if name, ok := t.Specials[c.Code()]; ok {
+		if glyph, ok := glyphSpecials[name]; ok {
+			return string(glyph)
+		}
+		return ""
+	}
+
+	row, ok := glyphSuites[t.Suite(c)]
+	if !ok {
+		return ""
+	}
+
+	rank := t.Rank(c)
+	column, ok := glyphRanks[rank]
+	if !ok {
+		n, err := strconv.Atoi(rank)
+		trump := row == glyphSuites["TRUMPS"]
+		if err != nil || n < 1 || n > 21 || !trump && (n < 2 || n > 10) {
+			return ""
+		}
+		column = rune(n)
+	}
+	return string(row + column)
+}
+
+// A set of deck types, by name.
+type deckTypeRegistry struct {
+	sync.RWMutex
+	types map[string]*DeckType
+}
+
+// Create an empty set of deck types.
+func newDeckTypeRegistry() *deckTypeRegistry {
+	return &deckTypeRegistry{types: map[string]*DeckType{}}
+}
+
+// Add a deck type.  If there is already one by the same name it is replaced, or if replace is false, kept and
+// ok=false returned.
+func (r *deckTypeRegistry) add(t *DeckType, replace bool) (ok bool) {
+	r.Lock()
+	defer r.Unlock()
+
+	if _, exists := r.types[t.Name]; exists && !replace {
+		return false
+	}
+
+	r.types[t.Name] = t
+	return true
+}
+
+// Find the deck type with the passed name.
+func (r *deckTypeRegistry) lookup(name string) (t *DeckType, ok bool) {
+	r.RLock()
+	defer r.RUnlock()
+
+	t, ok = r.types[name]
+	return
+}
+
+// All the deck types in the set, in no particular order.
+func (r *deckTypeRegistry) list() []*DeckType {
+	r.RLock()
+	defer r.RUnlock()
+
+	types := make([]*DeckType, 0, len(r.types))
+	for _, t := range r.types {
+		types = append(types, t)
+	}
+	return types
+}
+
+// Sort deck types by name.
+func sortDeckTypes(types []*DeckType) []*DeckType {
+	sort.Slice(types, func(i, j int) bool { return types[i].Name < types[j].Name })
+	return types
+}
+
+// The built in deck types, which every app can create decks of.  User defined deck types belong to the app whose store
+// they are kept in, see App.LookupDeckType.
+var deckTypes = newDeckTypeRegistry()
+
+// Add a built in deck type, replacing any already registered under the same name.
+func RegisterDeckType(t *DeckType) {
+	deckTypes.add(t, true)
+}
+
+// Find the built in deck type with the passed name.  An empty name is the standard deck type.
+func LookupDeckType(name string) (t *DeckType, ok bool) {
+	if name == "" {
+		name = StandardDeckTypeName
+	}
+	return deckTypes.lookup(name)
+}
+
+// All the built in deck types, in order of name.
+func DeckTypes() []*DeckType {
+	return sortDeckTypes(deckTypes.list())
+}
+
+// The type of the deck.  A user defined type is only known once the deck has been fetched through the app that has
+// it.  Decks of a type that isn't known are treated as standard decks.
+func (d *Deck) DeckType() *DeckType {
+	if t := d.deckType.Load(); t != nil {
+		return t
+	}
+
+	if t, ok := LookupDeckType(d.Type); ok {
+		return t
+	}
+
+	t, _ := LookupDeckType(StandardDeckTypeName)
+	return t
+}
+
+// Space separated codes of every rank in ranks of every suite in suites, suite by suite, each repeated copies times.
+func suitedCards(ranks string, suites string, copies int) string {
+	var codes []string
+	for _, suite := range strings.Fields(suites) {
+		for _, rank := range strings.Fields(ranks) {
+			for i := 0; i < copies; i++ {
+				codes = append(codes, rank+suite)
+			}
+		}
+	}
+	return strings.Join(codes, " ")
+}
+
+// The Spanish suites and ranks, which are shared by both sizes of Spanish deck.
+var (
+	spanishSuites = map[string]string{"O": "COINS", "C": "CUPS", "E": "SWORDS", "B": "CLUBS"}
+	spanishRanks  = map[string]string{"1": "ACE", "2": "2", "3": "3", "4": "4", "5": "5", "6": "6", "7": "7", "8": "8", "9": "9", "S": "KNAVE", "C": "KNIGHT", "R": "KING"}
+)
+
+// The Tarot ranks: the pips and four court cards of the suites.
+var tarotRanks = map[string]string{
+	"1": "ACE", "2": "2", "3": "3", "4": "4", "5": "5", "6": "6", "7": "7", "8": "8", "9": "9", "10": "10",
+	"J": "JACK", "C": "KNIGHT", "Q": "QUEEN", "K": "KING",
+}
+
+// The Tarot trumps, which are only numbered.
+var tarotTrumps = map[string]string{
+	"1": "1", "2": "2", "3": "3", "4": "4", "5": "5", "6": "6", "7": "7", "8": "8", "9": "9", "10": "10",
+	"11": "11", "12": "12", "13": "13", "14": "14", "15": "15", "16": "16", "17": "17", "18": "18", "19": "19",
+	"20": "20", "21": "21",
+}
+
+func init() {
+	RegisterDeckType(&DeckType{
+		Name:        StandardDeckTypeName,
+		Description: "The French 52 card deck.",
+		Cards:       STANDARD_DECK,
+		Suites:      SuiteMap,
+		Ranks:       RankMap,
+		Specials:    JokerMap,
+		Jokers:      JOKERS,
+		Glyphs:      true,
+	})
+
+	RegisterDeckType(&DeckType{
+		Name:        "piquet",
+		Description: "The 32 card French deck, sevens and up, for Piquet, Belote and Skat.",
+		Cards:       suitedCards("A 7 8 9 10 J Q K", "S D C H", 1),
+		Suites:      SuiteMap,
+		Ranks:       RankMap,
+		Specials:    JokerMap,
+		Jokers:      JOKERS,
+		Glyphs:      true,
+	})
+
+	RegisterDeckType(&DeckType{
+		Name:        "euchre",
+		Description: "The 24 card French deck, nines and up, for Euchre.",
+		Cards:       suitedCards("A 9 10 J Q K", "S D C H", 1),
+		Suites:      SuiteMap,
+		Ranks:       RankMap,
+		Specials:    JokerMap,
+		Jokers:      JOKERS,
+		Glyphs:      true,
+	})
+
+	RegisterDeckType(&DeckType{
+		Name:        "pinochle",
+		Description: "The 48 card Pinochle deck: two of every French card from nine up.",
+		Cards:       suitedCards("A 9 10 J Q K", "S D C H", 2),
+		Suites:      SuiteMap,
+		Ranks:       RankMap,
+		Glyphs:      true,
+	})
+
+	RegisterDeckType(&DeckType{
+		Name:        "spanish-40",
+		Description: "The 40 card Spanish deck, without eights and nines.",
+		Cards:       suitedCards("1 2 3 4 5 6 7 S C R", "O C E B", 1),
+		Suites:      spanishSuites,
+		Ranks:       spanishRanks,
+	})
+
+	RegisterDeckType(&DeckType{
+		Name:        "spanish-48",
+		Description: "The full 48 card Spanish deck.",
+		Cards:       suitedCards("1 2 3 4 5 6 7 8 9 S C R", "O C E B", 1),
+		Suites:      spanishSuites,
+		Ranks:       spanishRanks,
+	})
+
+	RegisterDeckType(&DeckType{
+		Name:        "german",
+		Description: "The 32 card German suited deck, for Skat and Schafkopf.",
+		Cards:       suitedCards("7 8 9 10 U O K A", "E G H S", 1),
+		Suites:      map[string]string{"E": "ACORNS", "G": "LEAVES", "H": "HEARTS", "S": "BELLS"},
+		Ranks:       map[string]string{"7": "7", "8": "8", "9": "9", "10": "10", "U": "UNTER", "O": "OBER", "K": "KING", "A": "ACE"},
+	})
+
+	RegisterDeckType(&DeckType{
+		Name:        "tarot",
+		Description: "The 78 card French Tarot deck: four suites of 14, 21 trumps and the Excuse.",
+		Cards: suitedCards("1 2 3 4 5 6 7 8 9 10 J C Q K", "S D C H", 1) + " " +
+			suitedCards("1 2 3 4 5 6 7 8 9 10 11 12 13 14 15 16 17 18 19 20 21", "T", 1) + " EX",
+		Suites:     map[string]string{"S": "SPADES", "D": "DIAMONDS", "C": "CLUBS", "H": "HEARTS", "T": "TRUMPS"},
+		Ranks:      tarotRanks,
+		Specials:   map[string]string{"EX": "THE EXCUSE"},
+		SuiteRanks: map[string]map[string]string{"T": tarotTrumps},
+		Glyphs:     true,
+	})
+}
@@ -0,0 +1,113 @@
+package toggleDecks
+
+import (
+	"log"
+	"time"
+)
+
+/*
+	Expiry of idle decks.  When the App has an IdleTTL, a deck that has not been touched for that long is treated as
+	gone, and a background reaper removes such decks from the store.
+
+	Reading a deck touches it without storing it, so reads never write to the store.  The new access time is stored
+	with the next change to the deck, or by the reaper, which stores the access time of every deck used since it last
+	ran.
+*/
+
+// Interface to the source of the current time, so that expiry can be tested without waiting on the real clock.
+type Clock interface {
+	Now() time.Time
+
+	// Start a ticker that delivers the time on the returned channel every d.  Call stop to shut it down.
+	Tick(d time.Duration) (ticks <-chan time.Time, stop func())
+}
+
+// The actual clock, which is the system time.
+type SystemClock struct{}
+
+// Implement the Clock interface
+func (c SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// Implement the Clock interface
+func (c SystemClock) Tick(d time.Duration) (ticks <-chan time.Time, stop func()) {
+	ticker := time.NewTicker(d)
+	return ticker.C, ticker.Stop
+}
+
+// The clock used for deck timestamps and expiry.  This is so we can mock it in tests.
+var TheClock Clock = SystemClock{}
+
+// Remove every deck that has been idle for longer than the App's IdleTTL from the store, and store the access time of
+// the rest that have been used since the last time.  Returns the number of decks removed.  Does nothing if the App has
+// no IdleTTL.
+func (a *App) ReapExpiredDecks() (reaped int) {
+	if a.IdleTTL <= 0 {
+		return 0
+	}
+
+	now := TheClock.Now()
+	for _, iid := range a.Store.List() {
+		deck, ok := a.Store.Get(iid)
+		if !ok {
+			continue
+		}
+
+		if !deck.Expired(a.IdleTTL, now) {
+			a.storeAccessTime(iid, deck)
+			continue
+		}
+
+		if err := a.Store.Delete(iid); err == nil {
+			reaped++
+		} else if err != ErrDeckNotFound {
+			_ = log.Output(1, "Error reaping expired deck "+iid+": "+err.Error())
+		}
+	}
+
+	return
+}
+
+// Start a background goroutine that reaps expired decks every interval.  The returned function stops the reaper, and
+// does not return until any reaping in progress is finished.
+func (a *App) StartReaper(interval time.Duration) (stop func()) {
+	ticks, stopTicker := TheClock.Tick(interval)
+	done := make(chan struct{})
+	finished := make(chan struct{})
+
+	go func() {
+		defer close(finished)
+		for {
+			select {
+			case <-ticks:
+				a.ReapExpiredDecks()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		stopTicker()
+		close(done)
+		<-finished
+	}
+}
+
+// Store the deck's access time if it has been used since it was last stored by the reaper.  A deck being changed is
+// left alone, as the change will store it.
+func (a *App) storeAccessTime(iid string, deck *Deck) {
+	if !deck.update.TryLock() {
+		return
+	}
+	defer deck.update.Unlock()
+
+	if !deck.takeTouched() {
+		return
+	}
+
+	if err := a.Store.Update(iid, deck); err != nil && err != ErrDeckNotFound {
+		_ = log.Output(1, "Error storing the access time of deck "+iid+": "+err.Error())
+	}
+}
@@ -0,0 +1,259 @@
+/*
+	Provably fair shuffles, by commit and reveal.
+
+	When a fair deck is created the server picks a secret server seed and publishes its hash.  The deck is then shuffled
+	once, with a client seed chosen by the players, and the server publishes a commitment to the order that shuffle
+	produced.  Neither seed alone decides the order, and the server can not change its seed without the published hash
+	giving it away.  Once every card has been drawn, or the deck is closed, the server seed is revealed, and anyone can
+	recompute the order with FairOrder and check it against the published hashes with VerifyFairShuffle.
+
+	Everything is hex encoded SHA-256:
+
+		server seed hash = SHA-256(server seed)
+		commitment       = SHA-256(server seed + ":" + the shuffled card codes, space separated, top first)
+
+	The order is a Fisher-Yates shuffle of the deck's original cards, in the order they were listed when the deck was
+	created.  For i from the last index down to 1, card i is swapped with card j, where j is uniform in [0, i].  Each j
+	is taken from a stream of big-endian 32 bit numbers, read in turn from the blocks HMAC-SHA256(key: server seed,
+	message: client seed + ":" + n) for n = 0, 1, 2...  A number v is used as j = v mod (i+1) only if v is below the
+	largest multiple of i+1 that fits in 32 bits; otherwise it is thrown away and the next one tried, so that no j is
+	more likely than another.
+
+	No cards are dealt from a fair deck until it has been shuffled, and none are dealt from it, or returned to it, once
+	its server seed is revealed.
+
+	Only that one shuffle is covered by the proof.  Cards returned to a fair deck at random, and piles shuffled, are
+	placed from crypto/rand.
+*/
+
+package toggleDecks
+
+import (
+	"crypto/hmac"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// Returned when asked to shuffle a fair deck that has already been shuffled, or has had its seed revealed.
+var ErrFairShuffleDone = errors.New("fair decks can only be shuffled once")
+
+// Returned when asked to deal from, or return cards to, a fair deck whose server seed has been revealed.  Anyone can
+// work out the order of the cards left from the seed, so they can't be dealt fairly.
+var ErrFairDeckClosed = errors.New("fair deck is closed")
+
+// Returned when asked to deal from a fair deck before it is shuffled, when there is no commitment to the order yet.
+var ErrFairDeckNotShuffled = errors.New("fair deck has not been shuffled")
+
+// Returned when asked to close a deck that isn't a fair deck.
+var ErrNotFair = errors.New("not a fair deck")
+
+// Returned by VerifyFairShuffle when the revealed server seed does not match the hash published for it.
+var ErrServerSeedMismatch = errors.New("server seed does not match its hash")
+
+// Returned by VerifyFairShuffle when the recomputed order does not match the commitment published for it.
+var ErrCommitmentMismatch = errors.New("shuffled order does not match its commitment")
+
+// The longest client seed accepted.
+const MaxClientSeedLength = 256
+
+// The commit and reveal state of a fair deck.
+type FairShuffle struct {
+	ServerSeed     string `json:"server_seed"`
+	ServerSeedHash string `json:"server_seed_hash"`
+	ClientSeed     string `json:"client_seed,omitempty"`
+	Commitment     string `json:"commitment,omitempty"`
+	Revealed       bool   `json:"revealed"`
+}
+
+// Start the fair shuffle of a deck, with a new secret server seed.
+func NewFairShuffle() (*FairShuffle, error) {
+	seed, err := randomHex(32)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FairShuffle{ServerSeed: seed, ServerSeedHash: HashServerSeed(seed)}, nil
+}
+
+// Hex encoded random bytes from crypto/rand.
+func randomHex(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := crand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// The published hash of a server seed.
+func HashServerSeed(serverSeed string) string {
+	sum := sha256.Sum256([]byte(serverSeed))
+	return hex.EncodeToString(sum[:])
+}
+
+// The published commitment to the order of a fair shuffle.  The server seed is part of it so that the commitment
+// gives nothing away about the order, however few cards the deck has.
+func CommitOrder(serverSeed string, cards []Card) string {
+	codes := make([]string, len(cards))
+	for i, c := range cards {
+		codes[i] = c.Code()
+	}
+
+	sum := sha256.Sum256([]byte(serverSeed + ":" + strings.Join(codes, " ")))
+	return hex.EncodeToString(sum[:])
+}
+
+// The order the cards are shuffled into by a fair shuffle with these seeds.  The cards passed are not changed.
+func FairOrder(serverSeed string, clientSeed string, cards []Card) []Card {
+	order := append([]Card{}, cards...)
+	r := &fairRand{mac: hmac.New(sha256.New, []byte(serverSeed)), clientSeed: clientSeed}
+	r.Shuffle(len(order), func(i, j int) {
+		order[i], order[j] = order[j], order[i]
+	})
+	return order
+}
+
+// Check a revealed fair shuffle against what was published before it was revealed.  original is the cards the deck was
+// created with, in the order they were listed.  Returns the order they were shuffled into.
+func VerifyFairShuffle(serverSeed string, serverSeedHash string, clientSeed string, commitment string, original []Card) (order []Card, err error) {
+	if !hmac.Equal([]byte(HashServerSeed(serverSeed)), []byte(strings.ToLower(serverSeedHash))) {
+		return nil, ErrServerSeedMismatch
+	}
+
+	order = FairOrder(serverSeed, clientSeed, original)
+	if !hmac.Equal([]byte(CommitOrder(serverSeed, order)), []byte(strings.ToLower(commitment))) {
+		return nil, ErrCommitmentMismatch
+	}
+
+	return order, nil
+}
+
+// The randomSource for fair shuffles: the stream of numbers described at the top of this file.
+type fairRand struct {
+	mac        hash.Hash
+	clientSeed string
+	block      uint64
+	buffer     []byte
+}
+
+// The next number in the stream.
+func (r *fairRand) next() uint32 {
+	if len(r.buffer) < 4 {
+		r.mac.Reset()
+		_, _ = fmt.Fprintf(r.mac, "%v:%v", r.clientSeed, r.block)
+		r.block++
+		r.buffer = r.mac.Sum(nil)
+	}
+
+	v := binary.BigEndian.Uint32(r.buffer)
+	r.buffer = r.buffer[4:]
+	return v
+}
+
+// A uniformly distributed number in [0, n), rejecting numbers past the last whole multiple of n.
+func (r *fairRand) Intn(n int) int {
+	limit := uint64(1<<32) - uint64(1<<32)%uint64(n)
+	for {
+		if v := uint64(r.next()); v < limit {
+			return int(v % uint64(n))
+		}
+	}
+}
+
+// A Fisher-Yates shuffle.
+func (r *fairRand) Shuffle(n int, swap func(i, j int)) {
+	fisherYates(r, n, swap)
+}
+
+// Shuffle a fair deck, with the passed client seed, or a generated one if it is empty.  Every card the deck was created
+// with is gathered back before shuffling, and the seed is revealed straight away if there is nothing to deal.
+func (d *Deck) ShuffleFair(clientSeed string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.checkShuffle(nil); err != nil {
+		return err
+	}
+
+	return d.shuffleFair(clientSeed)
+}
+
+// Do the fair shuffle.  Must be called with the deck's lock held, after checkShuffle.
+func (d *Deck) shuffleFair(clientSeed string) (err error) {
+	if len(clientSeed) > MaxClientSeedLength {
+		return fmt.Errorf("client seeds can be at most %v characters", MaxClientSeedLength)
+	}
+
+	if clientSeed == "" {
+		if clientSeed, err = randomHex(16); err != nil {
+			return err
+		}
+	}
+
+	if d.Fair == nil {
+		if d.Fair, err = NewFairShuffle(); err != nil {
+			return err
+		}
+	}
+
+	if len(d.Cards) != len(d.Original) {
+		d.collect()
+	}
+
+	d.Cards = FairOrder(d.Fair.ServerSeed, clientSeed, d.Original)
+	d.Fair.ClientSeed = clientSeed
+	d.Fair.Commitment = CommitOrder(d.Fair.ServerSeed, d.Cards)
+	d.Shuffled = true
+	d.revealIfExhausted()
+	d.Version++
+	return nil
+}
+
+// Close a fair deck, revealing its server seed.
+func (d *Deck) Reveal() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.Fair == nil {
+		return ErrNotFair
+	}
+
+	d.Fair.Revealed = true
+	d.Version++
+	return nil
+}
+
+// Check that cards can be dealt from the deck, or returned to it: that if it is a fair deck, it has been shuffled and
+// not closed.
+func (d *Deck) CanDeal() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.checkDeal()
+}
+
+// As CanDeal.  Must be called with the deck's lock held.
+func (d *Deck) checkDeal() error {
+	switch {
+	case d.Fair == nil:
+		return nil
+	case d.Fair.Revealed:
+		return ErrFairDeckClosed
+	case d.Fair.Commitment == "":
+		return ErrFairDeckNotShuffled
+	}
+	return nil
+}
+
+// Reveal the server seed of a shuffled fair deck once the last card has left it.  Must be called with the deck's lock
+// held.
+func (d *Deck) revealIfExhausted() {
+	if d.Fair != nil && d.Fair.Commitment != "" && len(d.Cards) == 0 {
+		d.Fair.Revealed = true
+	}
+}
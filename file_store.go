@@ -0,0 +1,400 @@
+package toggleDecks
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+/*
+	A DeckStore that keeps its decks on disk so that they survive a restart of the server.
+
+	Every change is appended to a log file as a single json line and fsync'd before the call returns.  Once the log has
+	grown by CompactEvery records, the whole store is written out as a snapshot (to a temporary file that is fsync'd and
+	then atomically renamed into place) and the log is emptied.  Opening the store loads the snapshot and replays the
+	log over it.  A record torn by a crash part way through a write is discarded on the next open.
+
+	Log records carry the whole deck rather than the change made to it, so replaying a record that is already in the
+	snapshot (a crash between the snapshot rename and emptying the log) is harmless.  User defined deck types are
+	logged and snapshotted along with the decks.
+*/
+
+// The files kept in the store's data directory.
+const (
+	snapshotFileName     = "decks.snapshot"
+	snapshotTempFileName = "decks.snapshot.tmp"
+	logFileName          = "decks.log"
+)
+
+// Number of log records written before the log is compacted into a snapshot, unless the store is told otherwise.
+const DefaultCompactEvery = 1000
+
+// The operations that can appear in the log.
+const (
+	logOpPut    = "put"
+	logOpDelete = "delete"
+	logOpClear  = "clear"
+	logOpType   = "type"
+)
+
+// A single line of the append only log.
+type logRecord struct {
+	Op   string    `json:"op"`
+	Id   string    `json:"id,omitempty"`
+	Deck *Deck     `json:"deck,omitempty"`
+	Type *DeckType `json:"type,omitempty"`
+}
+
+// The contents of a snapshot file.
+type storeSnapshot struct {
+	Decks map[string]*Deck     `json:"decks"`
+	Types map[string]*DeckType `json:"types,omitempty"`
+}
+
+// A DeckStore persisted to a local data directory.
+type FileDeckStore struct {
+	// Number of log records written before compacting.  Zero or less turns off automatic compaction.
+	CompactEvery int
+
+	mu      sync.Mutex
+	dir     string
+	decks   map[string]*Deck
+	types   map[string]*DeckType
+	log     *os.File
+	records int
+}
+
+// Open (creating it if needed) the file backed store in the passed directory, loading any decks already saved there.
+func NewFileDeckStore(dir string) (*FileDeckStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	s := &FileDeckStore{CompactEvery: DefaultCompactEvery, dir: dir, decks: map[string]*Deck{}, types: map[string]*DeckType{}}
+
+	// A temporary snapshot means we died while compacting, before the rename.  The old snapshot and log are intact.
+	if err := os.Remove(filepath.Join(dir, snapshotTempFileName)); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if err := s.loadSnapshot(); err != nil {
+		return nil, err
+	}
+
+	if err := s.replayLog(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Read the snapshot file, if there is one, into the store.
+func (s *FileDeckStore) loadSnapshot() error {
+	data, err := os.ReadFile(filepath.Join(s.dir, snapshotFileName))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var snap storeSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("corrupt snapshot %v: %v", snapshotFileName, err)
+	}
+
+	if snap.Decks != nil {
+		s.decks = snap.Decks
+	}
+	if snap.Types != nil {
+		s.types = snap.Types
+	}
+	return nil
+}
+
+// Apply every complete record in the log to the store, cut off any torn record at the end, and leave the log open
+// for appending.
+func (s *FileDeckStore) replayLog() error {
+	f, err := os.OpenFile(filepath.Join(s.dir, logFileName), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+
+	var good int64
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// Anything left without a newline is a write that never finished.
+			break
+		} else if err != nil {
+			_ = f.Close()
+			return err
+		}
+
+		var record logRecord
+		if json.Unmarshal(bytes.TrimSpace(line), &record) != nil {
+			// A damaged record can only be the last one written, so everything from here on is discarded.
+			break
+		}
+
+		s.apply(record)
+		s.records++
+		good += int64(len(line))
+	}
+
+	if err := f.Truncate(good); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	if _, err := f.Seek(good, io.SeekStart); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	s.log = f
+	return nil
+}
+
+// Apply a log record to the in memory copy of the decks.
+func (s *FileDeckStore) apply(record logRecord) {
+	switch record.Op {
+	case logOpPut:
+		if record.Deck != nil {
+			s.decks[record.Id] = record.Deck
+		}
+	case logOpDelete:
+		delete(s.decks, record.Id)
+	case logOpClear:
+		s.decks = map[string]*Deck{}
+	case logOpType:
+		if record.Type != nil {
+			s.types[record.Type.Name] = record.Type
+		}
+	}
+}
+
+// Durably append a record to the log and apply it to the decks in memory, then compact if it is time to.  Must be
+// called with the store lock held.
+func (s *FileDeckStore) commit(record logRecord) error {
+	if s.log == nil {
+		return fmt.Errorf("deck store is closed")
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	offset, err := s.log.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+
+	// Take a failed record back out of the log.  Half a record would be in the way of the next one, and a whole one
+	// would be replayed when the store is next opened, even though the caller was told it failed.
+	rollBack := func() {
+		_ = s.log.Truncate(offset)
+		_, _ = s.log.Seek(offset, io.SeekStart)
+	}
+
+	if _, err := s.log.Write(append(line, '\n')); err != nil {
+		rollBack()
+		return err
+	}
+
+	if err := s.log.Sync(); err != nil {
+		rollBack()
+		return err
+	}
+
+	s.apply(record)
+	s.records++
+
+	if s.CompactEvery > 0 && s.records >= s.CompactEvery {
+		// The change is already safe in the log, so a failed compaction is only worth a note; the next one will retry.
+		if err := s.compact(); err != nil {
+			_ = log.Output(1, "Error compacting the deck store: "+err.Error())
+		}
+	}
+	return nil
+}
+
+// Write every deck out as a new snapshot and empty the log.  Must be called with the store lock held.
+func (s *FileDeckStore) compact() error {
+	data, err := json.Marshal(storeSnapshot{Decks: s.decks, Types: s.types})
+	if err != nil {
+		return err
+	}
+
+	tempName := filepath.Join(s.dir, snapshotTempFileName)
+	f, err := os.OpenFile(tempName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tempName, filepath.Join(s.dir, snapshotFileName)); err != nil {
+		return err
+	}
+
+	if err := s.syncDir(); err != nil {
+		return err
+	}
+
+	if err := s.log.Truncate(0); err != nil {
+		return err
+	}
+
+	if _, err := s.log.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	s.records = 0
+	return s.log.Sync()
+}
+
+// Flush the directory entry changes (the snapshot rename) to disk.
+func (s *FileDeckStore) syncDir() error {
+	d, err := os.Open(s.dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
+
+// Force a compaction of the log into a snapshot now.
+func (s *FileDeckStore) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.log == nil {
+		return fmt.Errorf("deck store is closed")
+	}
+
+	return s.compact()
+}
+
+// Close the store's log file.  The store can not be used after it is closed.
+func (s *FileDeckStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.log == nil {
+		return nil
+	}
+
+	err := s.log.Close()
+	s.log = nil
+	return err
+}
+
+// Implement the DeckStore interface
+func (s *FileDeckStore) Create(iid string, deck *Deck) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.decks[iid]; ok {
+		return ErrDeckExists
+	}
+
+	return s.commit(logRecord{Op: logOpPut, Id: iid, Deck: deck})
+}
+
+// Implement the DeckStore interface
+func (s *FileDeckStore) Get(iid string) (deck *Deck, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deck, ok = s.decks[iid]
+	return
+}
+
+// Implement the DeckStore interface
+func (s *FileDeckStore) Update(iid string, deck *Deck) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.decks[iid]; !ok {
+		return ErrDeckNotFound
+	}
+
+	return s.commit(logRecord{Op: logOpPut, Id: iid, Deck: deck})
+}
+
+// Implement the DeckStore interface
+func (s *FileDeckStore) Delete(iid string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.decks[iid]; !ok {
+		return ErrDeckNotFound
+	}
+
+	return s.commit(logRecord{Op: logOpDelete, Id: iid})
+}
+
+// Implement the DeckStore interface
+func (s *FileDeckStore) List() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]string, 0, len(s.decks))
+	for id := range s.decks {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Implement the DeckStore interface
+func (s *FileDeckStore) Clear() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.commit(logRecord{Op: logOpClear})
+}
+
+// Implement the DeckTypeStore interface
+func (s *FileDeckStore) CreateDeckType(t *DeckType) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.types[t.Name]; ok {
+		return ErrDeckTypeExists
+	}
+
+	return s.commit(logRecord{Op: logOpType, Type: t})
+}
+
+// Implement the DeckTypeStore interface
+func (s *FileDeckStore) ListDeckTypes() []*DeckType {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	types := make([]*DeckType, 0, len(s.types))
+	for _, t := range s.types {
+		types = append(types, t)
+	}
+	return types
+}
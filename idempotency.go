@@ -0,0 +1,393 @@
+/*
+	Idempotency keys for drawing from and creating decks.
+
+	A client that isn't sure a request got through, because it timed out say, can send it again safely if it sent an
+	Idempotency-Key header with it.  The first successful response to a request with a key is remembered, and a request
+	sent again with the same key gets that response replayed, with an Idempotent-Replayed header, rather than drawing
+	more cards or creating another deck.  Requests that failed are not remembered, so they can be retried once whatever
+	was wrong is fixed.
+
+	A key is only good for the request it was first sent with: the same method, path, query and body.  Sending it with
+	a different request fails with 422 Unprocessable Entity.
+
+	Keys for draws are kept with the deck, and stored along with the draw itself, so a draw is never kept without the
+	key to replay it; they go with the deck when it is deleted or expires.  Keys for creating decks are kept by the App,
+	and are lost if the server restarts.  Either way, keys are forgotten once the App's IdempotencyWindow has passed
+	since their first response, and are cleared out as more keys are used.  Only so many keys are kept, for each deck
+	and for creating decks, and if there are more the oldest are forgotten early.
+*/
+
+package toggleDecks
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// How long responses to requests with idempotency keys are kept to be replayed, unless the App says otherwise.
+const DefaultIdempotencyWindow = 24 * time.Hour
+
+// The most idempotency keys kept with a deck.  When there are more, the oldest are forgotten early.
+const MaxDeckIdempotencyKeys = 256
+
+// The most idempotency keys kept for creating decks.  When there are more, the oldest are forgotten early.
+const MaxCreationIdempotencyKeys = 4096
+
+// The header carrying the idempotency key of a request.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// The header marking a response as a replay of the response to an earlier request with the same key.
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+// Idempotency keys are 1 to 255 printable ASCII characters, without spaces.
+var idempotencyKeyPattern = regexp.MustCompile(`^[\x21-\x7E]{1,255}$`)
+
+// The headers of a response that are replayed along with it.
+var replayedHeaders = []string{"Content-Type", "ETag"}
+
+// A response remembered so that it can be replayed.
+type IdempotentResponse struct {
+	// Identifies the request the response was to, so the key can't be reused for a different one.
+	Fingerprint string              `json:"fingerprint"`
+	Status      int                 `json:"status"`
+	Header      map[string][]string `json:"header,omitempty"`
+	Body        []byte              `json:"body"`
+	Created     time.Time           `json:"created"`
+}
+
+// Write the response again.
+func (ir IdempotentResponse) replay(w http.ResponseWriter) {
+	for name, values := range ir.Header {
+		w.Header().Del(name)
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(ir.Status)
+	if _, err := w.Write(ir.Body); err != nil {
+		_ = log.Output(1, "Error replaying response: "+err.Error())
+	}
+}
+
+// The key of a request, and its fingerprint.  The key is empty if the request didn't have one.
+type idempotentRequest struct {
+	key         string
+	fingerprint string
+}
+
+// Read the idempotency key of the request, if it has one, and fingerprint the request.  The body is read to do so,
+// and put back for the endpoint to read.  If the key is malformed, or the body can't be read, write an error and
+// return ok=false.
+// This is meant to be called as a helper from REST endpoints, it is not an endpoint itself.
+func getIdempotentRequest(w http.ResponseWriter, r *http.Request) (request idempotentRequest, ok bool) {
+	request.key = r.Header.Get(IdempotencyKeyHeader)
+	if len(request.key) == 0 {
+		return request, true
+	}
+
+	if !idempotencyKeyPattern.MatchString(request.key) {
+		WriteError(w, http.StatusBadRequest, ErrorBadIdempotencyKey, "Idempotency keys must be 1 to 255 printable ASCII characters, without spaces.")
+		return request, false
+	}
+
+	var body []byte
+	if r.Body != nil {
+		var err error
+		if body, err = io.ReadAll(http.MaxBytesReader(w, r.Body, MaxDeckCreateRequestSize)); err != nil {
+			WriteError(w, http.StatusBadRequest, ErrorBadRequest, fmt.Sprintf("Unable to read the request body: %v", err))
+			return request, false
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	hash := sha256.New()
+	for _, part := range []string{r.Method, r.URL.Path, r.URL.Query().Encode(), mediaType} {
+		_, _ = fmt.Fprintf(hash, "%d:%s\n", len(part), part)
+	}
+	hash.Write(body)
+	request.fingerprint = hex.EncodeToString(hash.Sum(nil))
+
+	return request, true
+}
+
+// Does the remembered response belong to the request?  If not, write an error.
+func (ir IdempotentResponse) check(w http.ResponseWriter, request idempotentRequest) bool {
+	if ir.Fingerprint != request.fingerprint {
+		WriteError(w, http.StatusUnprocessableEntity, ErrorIdempotencyKeyReused, fmt.Sprintf("Idempotency key %v was already used for a different request.", request.key))
+		return false
+	}
+	return true
+}
+
+// A response writer that holds on to the response written to it, so it can be remembered before it is sent.  Headers
+// go straight to the underlying writer; the status and body are only sent by flush.
+type recordingResponseWriter struct {
+	http.ResponseWriter
+	response IdempotentResponse
+}
+
+func newRecordingResponseWriter(w http.ResponseWriter, request idempotentRequest) *recordingResponseWriter {
+	return &recordingResponseWriter{ResponseWriter: w, response: IdempotentResponse{Fingerprint: request.fingerprint}}
+}
+
+// Implement the http.ResponseWriter interface
+func (rw *recordingResponseWriter) WriteHeader(status int) {
+	if rw.response.Status == 0 {
+		rw.response.Status = status
+		for _, name := range replayedHeaders {
+			if values := rw.Header().Values(name); len(values) != 0 {
+				if rw.response.Header == nil {
+					rw.response.Header = map[string][]string{}
+				}
+				rw.response.Header[name] = append([]string{}, values...)
+			}
+		}
+	}
+}
+
+// Implement the http.ResponseWriter interface
+func (rw *recordingResponseWriter) Write(data []byte) (int, error) {
+	if rw.response.Status == 0 {
+		rw.WriteHeader(http.StatusOK)
+	}
+	rw.response.Body = append(rw.response.Body, data...)
+	return len(data), nil
+}
+
+// Send the response that was written.
+func (rw *recordingResponseWriter) flush() {
+	if rw.response.Status == 0 {
+		return
+	}
+
+	rw.ResponseWriter.WriteHeader(rw.response.Status)
+	if _, err := rw.ResponseWriter.Write(rw.response.Body); err != nil {
+		_ = log.Output(1, "Error writing response: "+err.Error())
+	}
+}
+
+// The response written, if it is one to remember.
+func (rw *recordingResponseWriter) succeeded() (response IdempotentResponse, ok bool) {
+	response = rw.response
+	response.Created = TheClock.Now()
+	return response, response.Status >= 200 && response.Status < 300
+}
+
+// The response remembered for the idempotency key, if there is one that hasn't expired.
+func (d *Deck) idempotentResponse(key string, window time.Duration) (response IdempotentResponse, ok bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.forgetExpiredResponses(window)
+	response, ok = d.IdempotentResponses[key]
+	return
+}
+
+// Remember the response to the request with the idempotency key.
+func (d *Deck) rememberResponse(key string, response IdempotentResponse, window time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.forgetExpiredResponses(window)
+	if d.IdempotentResponses == nil {
+		d.IdempotentResponses = map[string]IdempotentResponse{}
+	}
+	d.IdempotentResponses[key] = response
+
+	for len(d.IdempotentResponses) > MaxDeckIdempotencyKeys {
+		oldest := ""
+		for k, r := range d.IdempotentResponses {
+			if len(oldest) == 0 || r.Created.Before(d.IdempotentResponses[oldest].Created) {
+				oldest = k
+			}
+		}
+		delete(d.IdempotentResponses, oldest)
+	}
+}
+
+// Forget the responses remembered for longer than the window.  Must be called with the lock held.
+func (d *Deck) forgetExpiredResponses(window time.Duration) {
+	now := TheClock.Now()
+	for key, response := range d.IdempotentResponses {
+		if !now.Before(response.Created.Add(window)) {
+			delete(d.IdempotentResponses, key)
+		}
+	}
+	if len(d.IdempotentResponses) == 0 {
+		d.IdempotentResponses = nil
+	}
+}
+
+// As beginDeckUpdate, but if the request has an idempotency key that the deck has a response for, replay that
+// response instead and return ok=false.  The returned writer is the one to write the response to, and the endpoint
+// doesn't store the deck itself: if the response is a success, done remembers it with the deck, if the request has a
+// key, and stores the deck along with it, all at once, before sending it.  If the deck can't be stored, the change is
+// undone and the request fails.
+// This is meant to be called as a helper from REST endpoints, it is not an endpoint itself.
+func (a *App) beginIdempotentDeckUpdate(w http.ResponseWriter, r *http.Request, iid string, deck *Deck) (rw http.ResponseWriter, done func(), ok bool) {
+	request, ok := getIdempotentRequest(w, r)
+	if !ok {
+		return nil, nil, false
+	}
+
+	idempotent := len(request.key) != 0 && a.IdempotencyWindow > 0
+
+	deck.update.Lock()
+
+	if idempotent {
+		if response, found := deck.idempotentResponse(request.key, a.IdempotencyWindow); found {
+			if response.check(w, request) {
+				response.replay(w)
+			}
+			deck.update.Unlock()
+			return nil, nil, false
+		}
+	}
+
+	if !checkIfMatch(w, r, deck) {
+		deck.update.Unlock()
+		return nil, nil, false
+	}
+
+	finish := startDeckUpdate(deck)
+	recorder := newRecordingResponseWriter(w, request)
+	return recorder, func() {
+		defer finish()
+
+		response, ok := recorder.succeeded()
+		if !ok {
+			recorder.flush()
+			return
+		}
+
+		if idempotent {
+			deck.rememberResponse(request.key, response, a.IdempotencyWindow)
+		}
+		if err := a.updateDeck(iid, deck); err != nil {
+			w.Header().Del("ETag")
+			WriteError(w, http.StatusInternalServerError, ErrorStorageFailed, "Unable to store the deck.")
+			return
+		}
+		recorder.flush()
+	}, true
+}
+
+// The idempotency keys used to create decks, and the responses to them.
+type creationKeys struct {
+	mu   sync.Mutex
+	keys map[string]*pendingResponse
+
+	// The keys that have responses, oldest first.
+	answered []string
+}
+
+// The response to a request with an idempotency key, once there is one.
+type pendingResponse struct {
+	fingerprint string
+
+	// Closed once the request is finished.  If it failed, response is nil, and the key is free to be used again.
+	ready    chan struct{}
+	response *IdempotentResponse
+}
+
+// Forget every response remembered, other than to requests still being carried out.
+func (c *creationKeys) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range c.answered {
+		delete(c.keys, key)
+	}
+	c.answered = nil
+}
+
+// Remember the response to the request with the idempotency key, forgetting the oldest responses if there are too
+// many.  Must be called with the lock held.
+func (c *creationKeys) answer(key string, pending *pendingResponse, response IdempotentResponse) {
+	pending.response = &response
+	c.answered = append(c.answered, key)
+
+	for len(c.answered) > MaxCreationIdempotencyKeys {
+		delete(c.keys, c.answered[0])
+		c.answered = c.answered[1:]
+	}
+}
+
+// Forget the responses remembered for longer than the window.  Must be called with the lock held.
+func (c *creationKeys) forgetExpiredResponses(window time.Duration) {
+	now := TheClock.Now()
+	for len(c.answered) != 0 && !now.Before(c.keys[c.answered[0]].response.Created.Add(window)) {
+		delete(c.keys, c.answered[0])
+		c.answered = c.answered[1:]
+	}
+}
+
+// Start creating a deck for a request.  If the request has an idempotency key that a deck was already created with,
+// replay the response to that request instead and return ok=false.  If a request with the same key is still being
+// carried out, wait for it first.  The returned writer is the one to write the response to, and done must be called
+// once it is written.
+// This is meant to be called as a helper from REST endpoints, it is not an endpoint itself.
+func (a *App) beginIdempotentCreate(w http.ResponseWriter, r *http.Request) (rw http.ResponseWriter, done func(), ok bool) {
+	request, ok := getIdempotentRequest(w, r)
+	if !ok {
+		return nil, nil, false
+	}
+
+	if len(request.key) == 0 || a.IdempotencyWindow <= 0 {
+		return w, func() {}, true
+	}
+
+	var pending *pendingResponse
+	for {
+		a.creationKeys.mu.Lock()
+		a.creationKeys.forgetExpiredResponses(a.IdempotencyWindow)
+		earlier, found := a.creationKeys.keys[request.key]
+		if !found {
+			pending = &pendingResponse{fingerprint: request.fingerprint, ready: make(chan struct{})}
+			if a.creationKeys.keys == nil {
+				a.creationKeys.keys = map[string]*pendingResponse{}
+			}
+			a.creationKeys.keys[request.key] = pending
+			a.creationKeys.mu.Unlock()
+			break
+		}
+		a.creationKeys.mu.Unlock()
+
+		if !(IdempotentResponse{Fingerprint: earlier.fingerprint}).check(w, request) {
+			return nil, nil, false
+		}
+
+		<-earlier.ready
+		if earlier.response != nil {
+			earlier.response.replay(w)
+			return nil, nil, false
+		}
+	}
+
+	recorder := newRecordingResponseWriter(w, request)
+	return recorder, func() {
+		defer recorder.flush()
+
+		a.creationKeys.mu.Lock()
+		defer a.creationKeys.mu.Unlock()
+
+		if response, ok := recorder.succeeded(); ok {
+			a.creationKeys.answer(request.key, pending, response)
+		} else {
+			delete(a.creationKeys.keys, request.key)
+		}
+		close(pending.ready)
+	}, true
+}
@@ -0,0 +1,187 @@
+/*
+	Named piles of cards that belong to a deck: players' hands, a discard pile, a community pile and so on.
+
+	Cards get into piles by being dealt from the deck, and move between piles, so every card in a pile came out of
+	its deck.  At all times the cards in the deck, the cards drawn from it and not returned, and the cards in its piles
+	add up to exactly the cards the deck was created with.  As with the deck itself, the first card in a pile is the
+	"top" card.
+*/
+
+package toggleDecks
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Returned when asked about a pile that the deck does not have.
+var ErrPileNotFound = errors.New("pile not found")
+
+// Returned when asked to create a pile under a name that the deck already has.
+var ErrPileExists = errors.New("pile already exists")
+
+// The pile related things that can happen to a deck that are kept in its history.
+const (
+	EventDeal = "deal"
+	EventMove = "move"
+)
+
+// A named pile of cards belonging to a deck.
+type Pile struct {
+	Cards []Card `json:"cards"`
+}
+
+// Add a new, empty, pile to the deck.
+func (d *Deck) CreatePile(name string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.Piles[name]; ok {
+		return ErrPileExists
+	}
+
+	if d.Piles == nil {
+		d.Piles = map[string]*Pile{}
+	}
+	d.Piles[name] = &Pile{Cards: []Card{}}
+	d.Version++
+	return nil
+}
+
+// The cards in the named pile, top first.
+func (d *Deck) GetPile(name string) (cards []Card, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	pile, ok := d.Piles[name]
+	if !ok {
+		return nil, ErrPileNotFound
+	}
+
+	return append([]Card{}, pile.Cards...), nil
+}
+
+// The names of the deck's piles, in alphabetical order, and how many cards each holds.
+func (d *Deck) PileSizes() (names []string, sizes []int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	names = make([]string, 0, len(d.Piles))
+	for name := range d.Piles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	sizes = make([]int, len(names))
+	for i, name := range names {
+		sizes[i] = len(d.Piles[name].Cards)
+	}
+	return
+}
+
+// Deal the requested number of cards from the top of the deck onto the top of the named pile, keeping their order.
+// As with Draw, if there are not enough cards left you get what there is, and nothing from a deck that can't deal.
+func (d *Deck) DrawToPile(name string, number int) (cards []Card, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	pile, ok := d.Piles[name]
+	if !ok {
+		return nil, ErrPileNotFound
+	}
+
+	if err := d.checkDeal(); err != nil {
+		return nil, err
+	}
+
+	if number > len(d.Cards) {
+		number = len(d.Cards)
+	}
+	if number < 0 {
+		number = 0
+	}
+
+	cards = append([]Card{}, d.Cards[:number]...)
+	d.Cards = d.Cards[number:]
+	pile.Cards = append(append([]Card{}, cards...), pile.Cards...)
+	d.History = append(d.History, DeckEvent{Time: TheClock.Now(), Action: EventDeal, Count: len(cards), Cards: cards, Pile: name})
+	d.revealIfExhausted()
+	d.Version++
+
+	return cards, nil
+}
+
+// Move cards from the top of one pile to the top of another, keeping their order.  If cards is empty, the top count
+// cards are moved (or as many as there are, and none if count is negative); otherwise exactly the listed cards are
+// moved, and every one of them must be in the pile the cards are moving from.
+func (d *Deck) MovePileCards(from string, to string, count int, cards []Card) (moved []Card, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	source, ok := d.Piles[from]
+	if !ok {
+		return nil, ErrPileNotFound
+	}
+
+	destination, ok := d.Piles[to]
+	if !ok {
+		return nil, ErrPileNotFound
+	}
+
+	if from == to {
+		return nil, fmt.Errorf("cannot move cards from pile %v to itself", from)
+	}
+
+	var left []Card
+	if len(cards) == 0 {
+		if count > len(source.Cards) {
+			count = len(source.Cards)
+		}
+		if count < 0 {
+			count = 0
+		}
+		moved = append([]Card{}, source.Cards[:count]...)
+		left = append([]Card{}, source.Cards[count:]...)
+	} else {
+		left = append([]Card{}, source.Cards...)
+		for _, c := range cards {
+			found := false
+			for i := range left {
+				if left[i].Code() == c.Code() {
+					moved = append(moved, left[i])
+					left = append(left[:i], left[i+1:]...)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("%v is not in pile %v", c, from)
+			}
+		}
+	}
+
+	source.Cards = left
+	destination.Cards = append(append([]Card{}, moved...), destination.Cards...)
+	d.History = append(d.History, DeckEvent{Time: TheClock.Now(), Action: EventMove, Count: len(moved), Cards: moved, From: from, Pile: to})
+	d.Version++
+
+	return moved, nil
+}
+
+// Shuffle the cards in the named pile.
+func (d *Deck) ShufflePile(name string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	pile, ok := d.Piles[name]
+	if !ok {
+		return ErrPileNotFound
+	}
+
+	d.random().Shuffle(len(pile.Cards), func(i, j int) {
+		pile.Cards[i], pile.Cards[j] = pile.Cards[j], pile.Cards[i]
+	})
+	d.Version++
+	return nil
+}
@@ -0,0 +1,164 @@
+/*
+	Errors sent back by the REST API.
+
+	Every failed request is answered with a JSON error envelope, whatever went wrong:
+
+		{"code": "bad_card", "message": "Invalid Card Identifier.", "request_id": "...", "details": [...]}
+
+	The code is stable and meant for programs to act on; the message is meant for people and may change.  The request
+	id is also sent in the X-Request-Id header of every response, and is the one the client sent in that header, if it
+	sent a usable one.  Details are only given by some errors, as noted below.
+
+		not_found					404	There is nothing at the requested path.
+		method_not_allowed			405	The path doesn't accept the request's method.
+		deck_id_required			400	No deck id was given.
+		deck_not_found				404	The deck doesn't exist.  Deleting several decks details the missing ids.
+		bad_card					400	Card codes were refused.  Details list each code, its position and why.
+		bad_count					400	A count of cards or decks is out of range.
+		bad_seed					400	A shuffle seed or client seed is malformed, or not allowed for the deck.
+		bad_shuffle_mode			400	The shuffle mode isn't known.
+		unknown_deck_type			400	No deck type has the requested name.
+		no_jokers					400	Jokers were asked for, but the deck type has none.
+		not_enough_cards			409	A strict draw asked for more cards than are left.
+		version_mismatch			412	The deck has changed since the version given in If-Match.
+		already_shuffled			409	A fair deck was asked to shuffle a second time.
+		fair_deck_closed			409	Cards were asked to be dealt from, or returned to, a closed fair deck.
+		fair_deck_not_shuffled		409	Cards were asked to be dealt from a fair deck that hasn't been shuffled.
+		not_fair					400	Only fair decks can be closed.
+		bad_filter					400	A filter for listing decks is malformed.
+		bad_cursor					400	A cursor for listing decks is malformed.
+		bad_labels					400	Labels or metadata are malformed, or beyond the limits on them.
+		bad_json					400	A JSON request body is malformed, or has fields that aren't known.
+		unsupported_media_type		415	A request body was sent that isn't application/json.
+		bad_idempotency_key			400	An Idempotency-Key header is malformed.
+		idempotency_key_reused		422	An idempotency key was already used for a different request.
+		missing_parameter			400	A required query parameter is missing.
+		bad_deck_type_definition	400	A deck type definition was refused.
+		deck_type_exists			409	A deck type of the same name already exists.
+		deck_types_not_stored		501	The deck store can't keep deck types, so none can be defined.
+		pile_not_found				404	The pile doesn't exist.
+		pile_exists					409	A pile of the same name already exists.
+		bad_request					400	The request can't be carried out, for the reason given in the message.
+		storage_failed				500	The deck store failed, and nothing was changed.
+*/
+
+package toggleDecks
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"regexp"
+)
+
+// A machine readable code for why a request failed.
+type ErrorCode string
+
+const (
+	ErrorNotFound              ErrorCode = "not_found"
+	ErrorMethodNotAllowed      ErrorCode = "method_not_allowed"
+	ErrorDeckIdRequired        ErrorCode = "deck_id_required"
+	ErrorDeckNotFound          ErrorCode = "deck_not_found"
+	ErrorBadCard               ErrorCode = "bad_card"
+	ErrorBadCount              ErrorCode = "bad_count"
+	ErrorBadSeed               ErrorCode = "bad_seed"
+	ErrorBadShuffleMode        ErrorCode = "bad_shuffle_mode"
+	ErrorUnknownDeckType       ErrorCode = "unknown_deck_type"
+	ErrorNoJokers              ErrorCode = "no_jokers"
+	ErrorNotEnoughCards        ErrorCode = "not_enough_cards"
+	ErrorVersionMismatch       ErrorCode = "version_mismatch"
+	ErrorAlreadyShuffled       ErrorCode = "already_shuffled"
+	ErrorFairDeckClosed        ErrorCode = "fair_deck_closed"
+	ErrorFairDeckNotShuffled   ErrorCode = "fair_deck_not_shuffled"
+	ErrorNotFair               ErrorCode = "not_fair"
+	ErrorBadFilter             ErrorCode = "bad_filter"
+	ErrorBadCursor             ErrorCode = "bad_cursor"
+	ErrorBadLabels             ErrorCode = "bad_labels"
+	ErrorBadJson               ErrorCode = "bad_json"
+	ErrorUnsupportedMediaType  ErrorCode = "unsupported_media_type"
+	ErrorBadIdempotencyKey     ErrorCode = "bad_idempotency_key"
+	ErrorIdempotencyKeyReused  ErrorCode = "idempotency_key_reused"
+	ErrorMissingParameter      ErrorCode = "missing_parameter"
+	ErrorBadDeckTypeDefinition ErrorCode = "bad_deck_type_definition"
+	ErrorDeckTypeExists        ErrorCode = "deck_type_exists"
+	ErrorDeckTypesNotStored    ErrorCode = "deck_types_not_stored"
+	ErrorPileNotFound          ErrorCode = "pile_not_found"
+	ErrorPileExists            ErrorCode = "pile_exists"
+	ErrorBadRequest            ErrorCode = "bad_request"
+	ErrorStorageFailed         ErrorCode = "storage_failed"
+)
+
+// The header carrying the id of a request, both in the request and in its response.
+const RequestIdHeader = "X-Request-Id"
+
+// Request ids sent by clients are used if they are up to 64 letters, digits, dots, dashes and underscores.
+var requestIdPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// The ID generator hook used to give requests an id when the client didn't send one.  This is so we can mock it in
+// tests.
+var TheRequestIdProvider RestIdProvider = GuidIdProvider{}
+
+// The object sent back when a request fails.
+type RestErrorMessage struct {
+	Code      ErrorCode   `json:"code"`
+	Message   string      `json:"message"`
+	RequestId string      `json:"request_id"`
+	Details   interface{} `json:"details,omitempty"`
+}
+
+// The object describing a card code that was refused.
+type RestCardError struct {
+	Code     string `json:"code"`
+	Position int    `json:"position"`
+	Reason   string `json:"reason"`
+}
+
+// Create the details of a bad_card error from the errors parsing the codes.
+func NewRestCardErrors(errs CardErrors) []RestCardError {
+	invalid := make([]RestCardError, len(errs))
+	for i, e := range errs {
+		invalid[i] = RestCardError{e.Code, e.Position, e.Err.Error()}
+	}
+	return invalid
+}
+
+// Indicate an error and write the error envelope describing it.
+func WriteError(w http.ResponseWriter, status int, code ErrorCode, message string) {
+	WriteErrorDetails(w, status, code, message, nil)
+}
+
+// Indicate an error and write the error envelope describing it, with details.
+func WriteErrorDetails(w http.ResponseWriter, status int, code ErrorCode, message string, details interface{}) {
+	rm := RestErrorMessage{Code: code, Message: message, RequestId: w.Header().Get(RequestIdHeader), Details: details}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if e := json.NewEncoder(w).Encode(rm); e != nil {
+		_ = log.Output(1, "Error encoding data to json"+e.Error())
+	}
+}
+
+// Middleware giving every request an id, sent back in the X-Request-Id header of its response.
+func RequestIdMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(w.Header().Get(RequestIdHeader)) == 0 {
+			id := r.Header.Get(RequestIdHeader)
+			if !requestIdPattern.MatchString(id) {
+				id = TheRequestIdProvider.GenerateIdentifier()
+			}
+			w.Header().Set(RequestIdHeader, id)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Handler answering requests for paths the API doesn't have.
+func NotFoundHandler(w http.ResponseWriter, r *http.Request) {
+	WriteError(w, http.StatusNotFound, ErrorNotFound, "There is nothing at "+r.URL.Path+".")
+}
+
+// Handler answering requests with a method the path doesn't accept.
+func MethodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
+	WriteError(w, http.StatusMethodNotAllowed, ErrorMethodNotAllowed, r.URL.Path+" does not accept "+r.Method+" requests.")
+}
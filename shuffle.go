@@ -0,0 +1,169 @@
+/*
+	The randomness behind shuffles.
+
+	Each deck is created with a shuffle mode that every later shuffle of it uses.
+
+	In the default, seeded, mode every shuffle is driven by a seed.  The seed is either supplied by the caller or
+	generated by TheSeedProvider, and the deck remembers the seed of its last shuffle so that the same order can be
+	reproduced later by shuffling the same cards with the same seed.
+
+	In secure mode every shuffle is an unbiased Fisher-Yates shuffle drawing on crypto/rand.  There is no seed, and the
+	order can not be predicted or reproduced.
+
+	In fair mode the deck is shuffled just once, in a way that players can check afterwards.  See fair.go.
+*/
+
+package toggleDecks
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"time"
+)
+
+// How a deck is shuffled.
+type ShuffleMode string
+
+const (
+	// Reproducible shuffles from a seed.  This is the default.
+	ShuffleSeeded ShuffleMode = ""
+
+	// Unpredictable shuffles from crypto/rand, for real-money and competitive games.
+	ShuffleSecure ShuffleMode = "secure"
+
+	// A single shuffle that can be proven fair once the deck is done with.
+	ShuffleFair ShuffleMode = "fair"
+)
+
+// Returned when asked to shuffle a deck with a seed when its shuffle mode doesn't use one.
+var ErrSeedNotAllowed = errors.New("shuffles of this deck can not be seeded")
+
+// Convert the name of a shuffle mode, as given to the REST api, to the shuffle mode.
+func ParseShuffleMode(name string) (ShuffleMode, error) {
+	switch name {
+	case "", "seeded":
+		return ShuffleSeeded, nil
+	case "secure":
+		return ShuffleSecure, nil
+	case "fair":
+		return ShuffleFair, nil
+	default:
+		return ShuffleSeeded, fmt.Errorf("%v is not a valid shuffle mode", name)
+	}
+}
+
+// The random numbers needed to shuffle cards and pick places in a deck.  *rand.Rand is one.
+type randomSource interface {
+	Intn(n int) int
+	Shuffle(n int, swap func(i, j int))
+}
+
+// The largest seed generated.  Seeds are kept to 53 bits so that they survive a round trip through a JavaScript number.
+const MaxGeneratedSeed = 1<<53 - 1
+
+// Interface to a provider of seeds for shuffles that were not given one.
+type SeedProvider interface {
+	GenerateSeed() int64
+}
+
+// The actual seed provider, which returns random seeds from the operating system, so that decks shuffled at the same
+// moment still get different orders.
+type RandomSeedProvider struct{}
+
+// Implement the SeedProvider interface
+func (p RandomSeedProvider) GenerateSeed() int64 {
+	var b [8]byte
+	if _, err := crand.Read(b[:]); err != nil {
+		return time.Now().UnixNano() & MaxGeneratedSeed
+	}
+	return int64(binary.BigEndian.Uint64(b[:]) & MaxGeneratedSeed)
+}
+
+// The seed generator hook used to grab a seed for a shuffle.  This is so we can mock it in tests.
+var TheSeedProvider SeedProvider = RandomSeedProvider{}
+
+// A source of random numbers for the passed seed.
+func newSeededRand(seed int64) *rand.Rand {
+	return rand.New(rand.NewSource(seed))
+}
+
+// A randomSource that draws on crypto/rand.
+type secureRand struct{}
+
+// A uniformly distributed random number in [0, n).  crypto/rand.Int rejects out of range values rather than
+// folding them back in with a modulus, so there is no bias toward low numbers.
+func (secureRand) Intn(n int) int {
+	i, err := crand.Int(crand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		// Without the operating system's randomness there is no secure way to carry on, and dealing a predictable
+		// deck would be worse than failing the request.
+		panic("crypto/rand failed: " + err.Error())
+	}
+	return int(i.Int64())
+}
+
+// A Fisher-Yates shuffle.
+func (r secureRand) Shuffle(n int, swap func(i, j int)) {
+	fisherYates(r, n, swap)
+}
+
+// A Fisher-Yates shuffle: every item is swapped with one chosen uniformly from itself and the items before it.
+func fisherYates(r randomSource, n int, swap func(i, j int)) {
+	for i := n - 1; i > 0; i-- {
+		swap(i, r.Intn(i+1))
+	}
+}
+
+// The source of randomness for one operation on the deck, according to its shuffle mode.  Must be called with the
+// deck's lock held.
+func (d *Deck) random() randomSource {
+	if d.ShuffleMode != ShuffleSeeded {
+		return secureRand{}
+	}
+	return newSeededRand(TheSeedProvider.GenerateSeed())
+}
+
+// Check that the deck can be shuffled, with the passed seed if it isn't nil.  Must be called with the deck's lock held.
+func (d *Deck) checkShuffle(seed *int64) error {
+	if seed != nil && d.ShuffleMode != ShuffleSeeded {
+		return ErrSeedNotAllowed
+	}
+
+	if d.ShuffleMode == ShuffleFair && d.Fair != nil && (d.Fair.Commitment != "" || d.Fair.Revealed) {
+		return ErrFairShuffleDone
+	}
+
+	return nil
+}
+
+// Shuffle the cards according to the deck's shuffle mode, with the passed seed if it isn't nil, and remember the seed
+// used.  Must be called with the deck's lock held.
+func (d *Deck) shuffle(seed *int64) error {
+	if err := d.checkShuffle(seed); err != nil {
+		return err
+	}
+
+	switch d.ShuffleMode {
+	case ShuffleFair:
+		return d.shuffleFair("")
+	case ShuffleSecure:
+		secureRand{}.Shuffle(len(d.Cards), d.Swap)
+		d.Seed = 0
+	default:
+		if seed == nil {
+			generated := TheSeedProvider.GenerateSeed()
+			seed = &generated
+		}
+
+		newSeededRand(*seed).Shuffle(len(d.Cards), d.Swap)
+		d.Seed = *seed
+	}
+
+	d.Shuffled = true
+	d.Version++
+	return nil
+}
@@ -0,0 +1,201 @@
+package tests
+
+import (
+	"errors"
+	"github.com/GamalielMasters/toggleDecks"
+	"strings"
+	"testing"
+)
+
+// Card Parser Tests
+
+// Every card of a standard deck, and its jokers, parse as themselves.
+func TestParseCardAcceptsEveryStandardCard(t *testing.T) {
+	for _, code := range strings.Fields(toggleDecks.STANDARD_DECK + " " + toggleDecks.JOKERS) {
+		card, err := toggleDecks.ParseCard(code)
+		if err != nil || card.Code() != code {
+			t.Errorf("%v did not parse as itself.  Got %q and error %v", code, card.Code(), err)
+		}
+	}
+}
+
+// Codes are refused for the reason they are wrong.
+func TestParseCardRefusesBadCodes(t *testing.T) {
+	cases := map[string]error{
+		"":     toggleDecks.ErrEmptyCardCode,
+		"11D":  toggleDecks.ErrUnknownRank,
+		"1S":   toggleDecks.ErrUnknownRank,
+		"9B":   toggleDecks.ErrUnknownSuite,
+		"ZZ":   toggleDecks.ErrUnknownCard,
+		" AS":  toggleDecks.ErrUnknownRank,
+		"AS ":  toggleDecks.ErrUnknownCard,
+		"A ":   toggleDecks.ErrUnknownSuite,
+		"X":    toggleDecks.ErrUnknownCard,
+		"ASAS": toggleDecks.ErrUnknownRank,
+	}
+
+	for code, expected := range cases {
+		_, err := toggleDecks.ParseCard(code)
+
+		var cardErr *toggleDecks.CardError
+		if !errors.As(err, &cardErr) || cardErr.Code != code {
+			t.Errorf("%q was not refused with a CardError naming it.  Got %v", code, err)
+			continue
+		}
+
+		if !errors.Is(err, expected) {
+			t.Errorf("%q was refused for the wrong reason.  Expected %v but got %v", code, expected, err)
+		}
+	}
+}
+
+// Cards the deck type can name, but doesn't include, are refused.
+func TestParseCardRefusesCardsNotInTheDeckType(t *testing.T) {
+	euchre, _ := toggleDecks.LookupDeckType("euchre")
+	if _, err := euchre.ParseCard("2S"); !errors.Is(err, toggleDecks.ErrCardNotInDeck) {
+		t.Errorf("A two was accepted in a euchre deck.  Got %v", err)
+	}
+
+	spanish, _ := toggleDecks.LookupDeckType("spanish-40")
+	if _, err := spanish.ParseCard("8O"); !errors.Is(err, toggleDecks.ErrCardNotInDeck) {
+		t.Errorf("An eight was accepted in a 40 card spanish deck.  Got %v", err)
+	}
+}
+
+// A list is refused whole, with every bad code and where it was.
+func TestParseCardsReportsEveryBadCode(t *testing.T) {
+	cards, err := toggleDecks.ParseCards([]string{"AS", "", "KH", "1S", "9B"})
+	if cards != nil {
+		t.Errorf("Cards were returned from a list with bad codes: %v", cards)
+	}
+
+	var errs toggleDecks.CardErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Bad codes were not refused with CardErrors.  Got %v", err)
+	}
+
+	if len(errs) != 3 || errs[0].Position != 1 || errs[1].Position != 3 || errs[2].Position != 4 {
+		t.Errorf("The wrong codes were refused: %v", errs)
+	}
+
+	if !errors.Is(errs[0], toggleDecks.ErrEmptyCardCode) || errs[1].Code != "1S" || errs[2].Code != "9B" {
+		t.Errorf("Codes were refused with the wrong details: %v", errs)
+	}
+}
+
+// The common alternative notations are accepted, and turned into the card's own code.
+func TestParseCardAcceptsAlternativeNotations(t *testing.T) {
+	cases := map[string]string{
+		"10h": "10H", "TH": "10H", "th": "10H", "0H": "10H", "10♥": "10H", "10♡": "10H", "T♥\uFE0F": "10H",
+		"as": "AS", "A♠": "AS", "q♦": "QD", "K♣": "KC", "rj": "RJ",
+	}
+
+	for code, expected := range cases {
+		card, err := toggleDecks.ParseCard(code)
+		if err != nil || card.Code() != expected {
+			t.Errorf("%q was not parsed as %v.  Got %q and error %v", code, expected, card.Code(), err)
+		}
+	}
+
+	// The suite symbols are found by the name of the suite, so they work for other deck types too.
+	tarot, _ := toggleDecks.LookupDeckType("tarot")
+	if card, err := tarot.ParseCard("c♥"); err != nil || card.Code() != "CH" {
+		t.Errorf("A tarot knight of hearts was not parsed.  Got %q and error %v", card.Code(), err)
+	}
+
+	// Deck types with neither a ten nor a rank named T don't get one.
+	german, _ := toggleDecks.LookupDeckType("german")
+	if card, err := german.ParseCard("TH"); err != nil || card.Code() != "10H" {
+		t.Errorf("A german ten was not parsed.  Got %q and error %v", card.Code(), err)
+	}
+	spanish, _ := toggleDecks.LookupDeckType("spanish-40")
+	if _, err := spanish.ParseCard("TO"); err == nil {
+		t.Error("A ten was accepted in a spanish deck.")
+	}
+}
+
+// Cards that have a Unicode glyph are given it.
+func TestDeckTypesGiveGlyphs(t *testing.T) {
+	standard, _ := toggleDecks.LookupDeckType("standard")
+	cases := map[string]string{"AS": "🂡", "10H": "🂺", "QD": "🃍", "KC": "🃞", "RJ": "🂿", "BJ": "🃏"}
+	for code, expected := range cases {
+		if glyph := standard.Glyph(toggleDecks.Card(code)); glyph != expected {
+			t.Errorf("%v has the wrong glyph.  Expected %v but got %q", code, expected, glyph)
+		}
+	}
+
+	tarot, _ := toggleDecks.LookupDeckType("tarot")
+	for code, expected := range map[string]string{"CS": "🂬", "1H": "🂱", "1T": "🃡", "21T": "🃵", "EX": "🃠"} {
+		if glyph := tarot.Glyph(toggleDecks.Card(code)); glyph != expected {
+			t.Errorf("Tarot %v has the wrong glyph.  Expected %v but got %q", code, expected, glyph)
+		}
+	}
+
+	// The glyphs are of French and Tarot cards, so other decks have none, even for cards named the same way.
+	for name, codes := range map[string][]string{"spanish-48": {"1O", "1B", "CB", "SB", "7C"}, "german": {"7H", "KH", "AH", "UH", "OH"}} {
+		deckType, _ := toggleDecks.LookupDeckType(name)
+		for _, code := range codes {
+			if glyph := deckType.Glyph(toggleDecks.Card(code)); glyph != "" {
+				t.Errorf("The %v card %v was given a glyph: %v", name, code, glyph)
+			}
+		}
+	}
+}
+
+func TestParseCardsAcceptsGoodCodes(t *testing.T) {
+	cards, err := toggleDecks.ParseCards([]string{"AS", "10D", "RJ", "AS"})
+	if err != nil || len(cards) != 4 || cards[1].Code() != "10D" {
+		t.Errorf("Good codes were not parsed.  Got %v and error %v", cards, err)
+	}
+}
+
+// Whatever it is given, the parser refuses it or returns a card of the deck type, and never panics.
+func FuzzParseCard(f *testing.F) {
+	for _, seed := range []string{"", "AS", "10D", "RJ", "1S", "11D", "EX", "\xff", "A\x00S", "T♥", "0♡\uFE0F"} {
+		f.Add(seed)
+	}
+
+	types := toggleDecks.DeckTypes()
+	f.Fuzz(func(t *testing.T, code string) {
+		for _, deckType := range types {
+			card, err := deckType.ParseCard(code)
+			if err != nil {
+				continue
+			}
+			if !deckType.Contains(card) {
+				t.Errorf("%q was accepted as %q, but is not in a %v deck.", code, card.Code(), deckType.Name)
+			}
+			if again, err := deckType.ParseCard(card.Code()); err != nil || again != card {
+				t.Errorf("%q parsed as %q in a %v deck, which doesn't parse as itself.", code, card.Code(), deckType.Name)
+			}
+		}
+	})
+}
+
+// Lists are split as the REST endpoints split them, so empty and oddly separated entries are covered too.
+func FuzzParseCards(f *testing.F) {
+	for _, seed := range []string{"", "AS,,KH", "AS,KD,AC,2C, 11D", "10h,TH,0H,10♥", ",", "RJ,BJ,1S"} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, list string) {
+		codes := strings.Split(list, ",")
+		cards, err := toggleDecks.ParseCards(codes)
+		if err == nil {
+			if len(cards) != len(codes) {
+				t.Errorf("%q parsed as %v cards, not %v.", list, len(cards), len(codes))
+			}
+			return
+		}
+
+		var errs toggleDecks.CardErrors
+		if !errors.As(err, &errs) || len(errs) == 0 {
+			t.Fatalf("%q was refused without saying which codes were wrong: %v", list, err)
+		}
+		for _, e := range errs {
+			if e.Position < 0 || e.Position >= len(codes) || codes[e.Position] != e.Code {
+				t.Errorf("%q was refused with a CardError for the wrong position: %v", list, e)
+			}
+		}
+	})
+}
@@ -25,3 +25,21 @@ func TestCardKnowsItsSuite(t *testing.T) {
 		t.Errorf("Card reports wrong suite. Expected 'CLUBS', got '%v'", card.Suite())
 	}
 }
+
+// Jokers are named by their whole code, and have no suite.
+func TestJokersHaveNoSuite(t *testing.T) {
+	red := toggleDecks.Card("RJ")
+	black := toggleDecks.Card("BJ")
+
+	if !red.IsJoker() || !black.IsJoker() || toggleDecks.Card("JS").IsJoker() {
+		t.Error("Cards are confused about which of them are jokers.")
+	}
+
+	if red.Rank() != "RED JOKER" || black.Rank() != "BLACK JOKER" {
+		t.Errorf("Jokers report the wrong names. Got '%v' and '%v'", red.Rank(), black.Rank())
+	}
+
+	if red.Suite() != "" || black.Suite() != "" {
+		t.Errorf("Jokers report a suite. Got '%v' and '%v'", red.Suite(), black.Suite())
+	}
+}
@@ -0,0 +1,171 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/GamalielMasters/toggleDecks"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// Stress tests for simultaneous requests.  These are most useful when run with the race detector (go test -race).
+
+// Draw from the deck through the router from inside a goroutine, returning the codes of the cards dealt.
+func drawConcurrently(t *testing.T, iid string, count int) []string {
+	req, err := http.NewRequest("POST", fmt.Sprintf("/api/v1/decks/%v/draw?count=%v", iid, count), nil)
+	if err != nil {
+		t.Error(err)
+		return nil
+	}
+
+	rr := httptest.NewRecorder()
+	app.Router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Recived wrong status code. Expected %v, got %v.", http.StatusOK, rr.Code)
+		return nil
+	}
+
+	var message toggleDecks.RestDrawMessage
+	if err := json.Unmarshal(rr.Body.Bytes(), &message); err != nil {
+		t.Error(err)
+		return nil
+	}
+
+	codes := make([]string, len(message.Cards))
+	for i, c := range message.Cards {
+		codes[i] = c.Code
+	}
+	return codes
+}
+
+// Many players hammering the same deck never get the same card, and between them they get the whole deck.
+func TestConcurrentDrawsNeverDealACardTwice(t *testing.T) {
+	iid := app.NewDeck("", true)
+
+	var wg sync.WaitGroup
+	results := make(chan []string, 200)
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results <- drawConcurrently(t, iid, 1)
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	seen := map[string]bool{}
+	for codes := range results {
+		for _, code := range codes {
+			if seen[code] {
+				t.Errorf("Card %v was dealt more than once.", code)
+			}
+			seen[code] = true
+		}
+	}
+
+	if len(seen) != 52 {
+		t.Errorf("Expected all 52 cards to be dealt exactly once, got %v distinct cards.", len(seen))
+	}
+}
+
+// The same holds when the players grab several cards at a time.
+func TestConcurrentMultiCardDrawsNeverDealACardTwice(t *testing.T) {
+	iid := app.NewDeck("", false)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	dealt := []string{}
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func(count int) {
+			defer wg.Done()
+			codes := drawConcurrently(t, iid, count)
+			mu.Lock()
+			dealt = append(dealt, codes...)
+			mu.Unlock()
+		}(i%4 + 1)
+	}
+	wg.Wait()
+
+	seen := map[string]bool{}
+	for _, code := range dealt {
+		if seen[code] {
+			t.Errorf("Card %v was dealt more than once.", code)
+		}
+		seen[code] = true
+	}
+
+	if len(dealt) != 52 {
+		t.Errorf("Expected the whole deck to be dealt, got %v cards.", len(dealt))
+	}
+}
+
+// Decks can be created, opened, listed and drawn from all at the same time.
+func TestConcurrentMixedRequests(t *testing.T) {
+	app.ClearTheDatabase()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			iid := app.NewDeck("", true)
+			for _, request := range []struct{ method, url string }{
+				{"GET", "/api/v1/decks"},
+				{"GET", fmt.Sprintf("/api/v1/decks/%v", iid)},
+				{"POST", fmt.Sprintf("/api/v1/decks/%v/draw?count=5", iid)},
+			} {
+				req, _ := http.NewRequest(request.method, request.url, nil)
+				rr := httptest.NewRecorder()
+				app.Router.ServeHTTP(rr, req)
+				if rr.Code != http.StatusOK {
+					t.Errorf("%v %v failed with status %v", request.method, request.url, rr.Code)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(app.Store.List()) != 50 {
+		t.Errorf("Expected 50 decks after concurrent creation, got %v", len(app.Store.List()))
+	}
+}
+
+// The deck itself is safe to share, not just through the REST layer.
+func TestConcurrentDeckDrawAndShuffle(t *testing.T) {
+	deck := toggleDecks.CreateFullDeck()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	dealt := map[toggleDecks.Card]int{}
+	for i := 0; i < 60; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i%10 == 0 {
+				deck.Shuffle()
+			}
+			for _, c := range deck.Draw(1) {
+				mu.Lock()
+				dealt[c]++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for c, n := range dealt {
+		if n != 1 {
+			t.Errorf("Card %v was dealt %v times.", c, n)
+		}
+	}
+
+	if len(dealt)+deck.Len() != 52 {
+		t.Errorf("Cards went missing: %v dealt and %v left in the deck.", len(dealt), deck.Len())
+	}
+}
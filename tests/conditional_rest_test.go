@@ -0,0 +1,119 @@
+package tests
+
+import (
+	"fmt"
+	"github.com/GamalielMasters/toggleDecks"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+// Test conditional requests on decks, with ETags.
+
+// Every change to a deck changes its version, and so the ETag it is opened with.
+func TestDeckVersionChangesWithTheDeck(t *testing.T) {
+	iid := app.NewDeck("AS KH 8C", false)
+	url := fmt.Sprintf("/api/v1/decks/%v", iid)
+
+	first := DoHeaderRequest(t, "GET", url, "", nil).Header().Get("ETag")
+	if first != `"0"` {
+		t.Errorf("A new deck has the wrong ETag.  Expected \"0\", got %v", first)
+	}
+
+	DoRequest(t, "POST", url+"/draw")
+	DoRequest(t, "POST", url+"/shuffle")
+
+	rr := DoHeaderRequest(t, "GET", url, "", nil)
+	message := decodeDeckMessage(t, rr.Body.String())
+	if etag := rr.Header().Get("ETag"); etag != `"2"` || message.Version == nil || *message.Version != 2 {
+		t.Errorf("The deck's version did not follow its changes.  Got ETag %v and version %v", etag, message.Version)
+	}
+}
+
+// A deck that hasn't changed since the client last saw it isn't sent again.
+func TestOpenDeckIfNoneMatch(t *testing.T) {
+	iid := app.NewDeck("AS KH 8C", false)
+	url := fmt.Sprintf("/api/v1/decks/%v", iid)
+
+	rr := DoHeaderRequest(t, "GET", url, "", map[string]string{"If-None-Match": `"0"`})
+	if rr.Code != http.StatusNotModified || rr.Body.Len() != 0 || rr.Header().Get("ETag") != `"0"` {
+		t.Errorf("An unchanged deck was sent again.  Got %v %v", rr.Code, rr.Body.String())
+	}
+
+	DoRequest(t, "POST", url+"/draw")
+
+	if rr = DoHeaderRequest(t, "GET", url, "", map[string]string{"If-None-Match": `W/"0"`}); rr.Code != http.StatusOK {
+		t.Errorf("A changed deck was not sent.  Got %v", rr.Code)
+	}
+}
+
+// Changes made if the deck matches fail, and change nothing, when someone else has changed it first.
+func TestChangesIfMatch(t *testing.T) {
+	iid := app.NewDeck("AS KH 8C QD", false)
+	url := fmt.Sprintf("/api/v1/decks/%v", iid)
+
+	if rr := DoHeaderRequest(t, "POST", url+"/draw", "", map[string]string{"If-Match": `"0"`}); rr.Code != http.StatusOK || rr.Header().Get("ETag") != `"1"` {
+		t.Errorf("A draw from the expected version failed.  Got %v %v", rr.Code, rr.Body.String())
+	}
+
+	for _, path := range []string{"/draw", "/shuffle", "/return?cards=AS"} {
+		rr := DoHeaderRequest(t, "POST", url+path, "", map[string]string{"If-Match": `"0"`})
+		if rr.Code != http.StatusPreconditionFailed {
+			t.Errorf("%v of a changed deck did not fail.  Got %v %v", path, rr.Code, rr.Body.String())
+		}
+	}
+
+	deck, _ := app.GetDeck(iid)
+	if deck.Len() != 3 || deck.Version != 1 {
+		t.Errorf("Failed requests changed the deck: %v cards left at version %v", deck.Len(), deck.Version)
+	}
+
+	if rr := DoHeaderRequest(t, "POST", url+"/return?cards=AS", "", map[string]string{"If-Match": `"3", "1"`}); rr.Code != http.StatusOK {
+		t.Errorf("A return matching one of the ETags failed.  Got %v %v", rr.Code, rr.Body.String())
+	}
+
+	if rr := DoHeaderRequest(t, "POST", url+"/draw", "", map[string]string{"If-Match": "*"}); rr.Code != http.StatusOK {
+		t.Errorf("A draw matching any ETag failed.  Got %v %v", rr.Code, rr.Body.String())
+	}
+}
+
+// Of many clients changing the same version of a deck at once, exactly one succeeds.
+func TestOnlyOneChangeToAVersionSucceeds(t *testing.T) {
+	iid := app.NewDeck("", false)
+	url := fmt.Sprintf("/api/v1/decks/%v/draw", iid)
+
+	const clients = 20
+	codes := make(chan int, clients)
+	var wg sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- DoHeaderRequest(t, "POST", url, "", map[string]string{"If-Match": `"0"`}).Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	succeeded := 0
+	for code := range codes {
+		if code == http.StatusOK {
+			succeeded++
+		} else if code != http.StatusPreconditionFailed {
+			t.Errorf("A draw failed with %v.", code)
+		}
+	}
+
+	deck, _ := app.GetDeck(iid)
+	if succeeded != 1 || deck.Len() != 51 {
+		t.Errorf("%v draws of the same version succeeded, leaving %v cards.", succeeded, deck.Len())
+	}
+}
+
+// The error for a changed deck says so.
+func TestVersionMismatchError(t *testing.T) {
+	iid := app.NewDeck("AS", false)
+
+	rr := DoHeaderRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/shuffle", iid), "", map[string]string{"If-Match": `"7"`})
+	CheckErrorResponse(t, rr, http.StatusPreconditionFailed, toggleDecks.ErrorVersionMismatch)
+}
@@ -0,0 +1,73 @@
+package tests
+
+import (
+	"github.com/GamalielMasters/toggleDecks"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// Test creating decks from a JSON request body.
+
+// The cards and options can be sent as JSON instead of in the query string.
+func TestCreateCustomDeckFromJSON(t *testing.T) {
+	actual, status := DoCreateJSONRequest(t, `{"cards": ["AS", "kd", "10♥"], "deck_count": 2, "metadata": {"table": 7}}`)
+
+	if status != http.StatusOK {
+		t.Fatalf("Recived wrong status code. Expected %v, got %v: %v", http.StatusOK, status, actual)
+	}
+
+	expected := `{"deck_id":"a251071b-662f-44b6-ba11-e24863039c59","shuffled":false,"remaining":6,"version":0,"metadata":{"table":7}}` + "\n"
+	if expected != actual {
+		t.Errorf("Wrong result returned.\n\tExpected : %v\n\tGot      : %v", expected, actual)
+	}
+
+	deck, _ := app.GetDeck("a251071b-662f-44b6-ba11-e24863039c59")
+	if codes := strings.Join(strings.Fields(deck.String()), " "); codes != "AS KD 10H AS KD 10H" {
+		t.Errorf("Deck is not configured with the correct cards.  Got %v", codes)
+	}
+}
+
+// Seeds, shuffle modes and deck types work as they do in the query string.
+func TestCreateSeededDeckOfATypeFromJSON(t *testing.T) {
+	actual, status := DoCreateJSONRequest(t, `{"type": "euchre", "seed": 42, "shuffle_mode": "seeded"}`)
+
+	if status != http.StatusOK || !strings.Contains(actual, `"seed":42`) || !strings.Contains(actual, `"type":"euchre"`) || !strings.Contains(actual, `"remaining":24`) {
+		t.Errorf("A seeded euchre deck was not created.  Got %v %v", status, actual)
+	}
+}
+
+// Malformed JSON, unknown fields and bad options are refused with structured errors.
+func TestCreateDeckFromBadJSON(t *testing.T) {
+	cases := map[string]string{
+		`{"cards": ["AS"`:                       "bad_json",
+		`{"cards": "AS,KD"}`:                    "bad_json",
+		`{"cardz": ["AS"]}`:                     "bad_json",
+		`{"cards": ["AS"]} {}`:                  "bad_json",
+		`{"cards": ["AS", "1S"]}`:               "bad_card",
+		`{"deck_count": 0}`:                     "bad_count",
+		`{"type": "nonsense"}`:                  "unknown_deck_type",
+		`{"shuffle_mode": "sloppy"}`:            "bad_shuffle_mode",
+		`{"seed": 1, "shuffle_mode": "secure"}`: "bad_seed",
+	}
+
+	for body, code := range cases {
+		actual, status := DoCreateJSONRequest(t, body)
+		if status != http.StatusBadRequest || !strings.Contains(actual, `"code":"`+code+`"`) {
+			t.Errorf("%v was not refused with %v.  Got %v %v", body, code, status, actual)
+		}
+	}
+}
+
+// A body that isn't JSON is refused rather than ignored, which would create a full deck instead of the one described.
+func TestCreateDeckWithABodyThatIsNotJSON(t *testing.T) {
+	for _, contentType := range []string{"", "text/plain"} {
+		app.ClearTheDatabase()
+		rr := DoHeaderRequest(t, "POST", "/api/v1/decks", `{"cards": ["AS"]}`, map[string]string{"Content-Type": contentType})
+		CheckErrorResponse(t, rr, http.StatusUnsupportedMediaType, toggleDecks.ErrorUnsupportedMediaType)
+
+		if ids := app.Store.List(); len(ids) != 0 {
+			t.Errorf("A deck was created from a body sent as %q.", contentType)
+		}
+	}
+}
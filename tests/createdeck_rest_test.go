@@ -1,6 +1,8 @@
 package tests
 
 import (
+	"encoding/json"
+	"github.com/GamalielMasters/toggleDecks"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -17,12 +19,12 @@ func TestCreateNewDefaultDeck(t *testing.T) {
 		t.Errorf("Recived wrong status code. Expected %v, got %v.", http.StatusOK, status)
 	}
 
-	expected := `{"deck_id":"a251071b-662f-44b6-ba11-e24863039c59","shuffled":false,"remaining":52}` + "\n"
+	expected := `{"deck_id":"a251071b-662f-44b6-ba11-e24863039c59","shuffled":false,"remaining":52,"version":0}` + "\n"
 	if expected != actual {
 		t.Errorf("Wrong result returned.\n\tExpected : %v\n\tGot      : %v", expected, actual)
 	}
 
-	if len(app.TheDecks) != 1 {
+	if len(app.Store.List()) != 1 {
 		t.Error("Deck was not entered in internal map.")
 	}
 }
@@ -35,7 +37,7 @@ func TestCreateNewShuffledDeck(t *testing.T) {
 		t.Errorf("Recived wrong status code. Expected %v, got %v.", http.StatusOK, status)
 	}
 
-	expected := `{"deck_id":"a251071b-662f-44b6-ba11-e24863039c59","shuffled":true,"remaining":52}` + "\n"
+	expected := `{"deck_id":"a251071b-662f-44b6-ba11-e24863039c59","shuffled":true,"remaining":52,"version":1,"seed":12345}` + "\n"
 
 	if expected != actual {
 		t.Errorf("Wrong result returned.\n\tExpected : %v\n\tGot      : %v", expected, actual)
@@ -49,7 +51,7 @@ func TestCreateNewCustomDeck(t *testing.T) {
 		t.Errorf("Recived wrong status code. Expected %v, got %v.", http.StatusOK, status)
 	}
 
-	expected := `{"deck_id":"a251071b-662f-44b6-ba11-e24863039c59","shuffled":false,"remaining":5}` + "\n"
+	expected := `{"deck_id":"a251071b-662f-44b6-ba11-e24863039c59","shuffled":false,"remaining":5,"version":0}` + "\n"
 
 	if expected != actual {
 		t.Errorf("Wrong result returned.\n\tExpected : %v\n\tGot      : %v", expected, actual)
@@ -72,14 +74,14 @@ func TestCreateNewCustomShuffledDeck(t *testing.T) {
 		t.Errorf("Recived wrong status code. Expected %v, got %v.", http.StatusOK, status)
 	}
 
-	expected := `{"deck_id":"a251071b-662f-44b6-ba11-e24863039c59","shuffled":true,"remaining":5}` + "\n"
+	expected := `{"deck_id":"a251071b-662f-44b6-ba11-e24863039c59","shuffled":true,"remaining":5,"version":1,"seed":12345}` + "\n"
 
 	if expected != actual {
 		t.Errorf("Wrong result returned.\n\tExpected : %v\n\tGot      : %v", expected, actual)
 	}
 
 	expectedDeck := "AS KD AC 2C KH"
-	generatedDeck := app.TheDecks["a251071b-662f-44b6-ba11-e24863039c59"]
+	generatedDeck, _ := app.GetDeck("a251071b-662f-44b6-ba11-e24863039c59")
 	actualDeck := generatedDeck.String()
 
 	if actualDeck == expectedDeck {
@@ -99,14 +101,14 @@ func TestCreateCustomDeckWithRepeatedCards(t *testing.T) {
 		t.Errorf("Recived wrong status code. Expected %v, got %v.", http.StatusOK, status)
 	}
 
-	expected := `{"deck_id":"a251071b-662f-44b6-ba11-e24863039c59","shuffled":false,"remaining":10}` + "\n"
+	expected := `{"deck_id":"a251071b-662f-44b6-ba11-e24863039c59","shuffled":false,"remaining":10,"version":0}` + "\n"
 
 	if expected != actual {
 		t.Errorf("Wrong result returned.\n\tExpected : %v\n\tGot      : %v", expected, actual)
 	}
 
 	expectedDeck := "AS KD AC 2C KH AS KD AC 2C KH"
-	generatedDeck := app.TheDecks["a251071b-662f-44b6-ba11-e24863039c59"]
+	generatedDeck, _ := app.GetDeck("a251071b-662f-44b6-ba11-e24863039c59")
 	actualDeck := generatedDeck.String()
 
 	if actualDeck != expectedDeck {
@@ -131,6 +133,55 @@ func TestCreateCustomDeckWithInvalidCards(t *testing.T) {
 	}
 }
 
+// Empty entries are refused rather than crashing the server, and every bad code is listed, with where it was.
+func TestCreateCustomDeckListsEveryInvalidCard(t *testing.T) {
+	actual, status := DoCreateRequest(t, "POST", "/api/v1/decks?cards=AS,,KH,1S,9B")
+
+	if status != http.StatusBadRequest {
+		t.Fatalf("Did not respond with proper error to bad cards.  Expected %v but got %v", http.StatusBadRequest, status)
+	}
+
+	var message struct {
+		Code         toggleDecks.ErrorCode       `json:"code"`
+		InvalidCards []toggleDecks.RestCardError `json:"details"`
+	}
+	if err := json.Unmarshal([]byte(actual), &message); err != nil {
+		t.Fatalf("The error was not a JSON description of the bad cards: %v\n\t%v", err, actual)
+	}
+
+	if message.Code != toggleDecks.ErrorBadCard {
+		t.Errorf("The error has the wrong code.  Expected %v but got %v", toggleDecks.ErrorBadCard, message.Code)
+	}
+
+	expected := []toggleDecks.RestCardError{
+		{Code: "", Position: 1, Reason: toggleDecks.ErrEmptyCardCode.Error()},
+		{Code: "1S", Position: 3, Reason: toggleDecks.ErrUnknownRank.Error()},
+		{Code: "9B", Position: 4, Reason: toggleDecks.ErrUnknownSuite.Error()},
+	}
+	if len(message.InvalidCards) != len(expected) {
+		t.Fatalf("The wrong cards were listed as invalid.  Expected %v but got %v", expected, message.InvalidCards)
+	}
+	for i := range expected {
+		if message.InvalidCards[i] != expected[i] {
+			t.Errorf("Invalid card %v was described wrongly.  Expected %v but got %v", i, expected[i], message.InvalidCards[i])
+		}
+	}
+}
+
+// Cards can be given in other common notations, but the deck is made of the cards' own codes.
+func TestCreateCustomDeckWithAlternativeNotations(t *testing.T) {
+	_, status := DoCreateRequest(t, "POST", "/api/v1/decks?cards=10h,TS,0D,q%E2%99%A3,a%E2%99%A1")
+
+	if status != http.StatusOK {
+		t.Fatalf("Alternative notations were refused.  Expected %v but got %v", http.StatusOK, status)
+	}
+
+	deck, _ := app.GetDeck(GuidMock{}.GenerateIdentifier())
+	if actual := deck.String(); actual != "10H 10S 10D QC AH" {
+		t.Errorf("Deck is not configured with the correct cards.\n\tExpected: 10H 10S 10D QC AH\n\tGot:     %v", actual)
+	}
+}
+
 // If you ask for more then one deck, each one gets a different ID.
 func TestMultipleDecksGetDifferentIds(t *testing.T) {
 	// Cannot use DoCreateRequest here because it installs the GUID mock, which defeats what we are testing here.
@@ -150,7 +201,7 @@ func TestMultipleDecksGetDifferentIds(t *testing.T) {
 		}
 	}
 
-	if len(app.TheDecks) != 3 {
-		t.Errorf("Not all decks were added.  Expected 3 but got %v\nThis might mean multiple decks were created with the same ID.", len(app.TheDecks))
+	if len(app.Store.List()) != 3 {
+		t.Errorf("Not all decks were added.  Expected 3 but got %v\nThis might mean multiple decks were created with the same ID.", len(app.Store.List()))
 	}
 }
@@ -0,0 +1,222 @@
+package tests
+
+import (
+	"fmt"
+	"github.com/GamalielMasters/toggleDecks"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test deck types defined by users.
+
+// Deck types can't be removed, so every test defines its own, under a name no other run of the test has used.
+func uniqueDeckTypeName(base string) string {
+	return fmt.Sprintf("%v-%v", base, time.Now().UnixNano())
+}
+
+// A definition of a small zoo themed deck type under the passed name.
+func zooDefinition(name string) string {
+	return `{
+		"name": "` + name + `",
+		"description": "Animals in cages.",
+		"suites": [{"code": "L", "name": "LIONS"}, {"code": "T", "name": "TIGERS"}],
+		"ranks": [{"code": "1", "name": "CUB"}, {"code": "2", "name": "ADULT"}],
+		"specials": [{"code": "ZK", "name": "ZOOKEEPER"}],
+		"counts": {"1L": 3, "2T": 0},
+		"metadata": {"designer": "Jo", "version": 2}
+	}`
+}
+
+// A defined deck type can be used to create decks, which name their cards in its vocabulary.
+func TestDefineAndUseADeckType(t *testing.T) {
+	name := uniqueDeckTypeName("zoo")
+	actual, status := DoBodyRequest(t, "POST", "/api/v1/deck-types", zooDefinition(name))
+
+	if status != http.StatusOK {
+		t.Fatalf("Recived wrong status code. Expected %v, got %v: %v", http.StatusOK, status, actual)
+	}
+
+	if !strings.Contains(actual, `"size":6`) || !strings.Contains(actual, `"metadata":{"designer":"Jo","version":2}`) || !strings.Contains(actual, `"user_defined":true`) {
+		t.Errorf("Wrong deck type returned: %v", actual)
+	}
+
+	listed, _ := DoRequest(t, "GET", "/api/v1/deck-types")
+	if !strings.Contains(listed, `"name":"`+name+`"`) {
+		t.Errorf("Defined deck type is not listed: %v", listed)
+	}
+
+	actual, status = DoCreateRequest(t, "POST", "/api/v1/decks?type="+name)
+	expected := `{"deck_id":"a251071b-662f-44b6-ba11-e24863039c59","shuffled":false,"remaining":6,"version":0,"type":"` + name + `"}` + "\n"
+	if status != http.StatusOK || expected != actual {
+		t.Errorf("Wrong result returned.\n\tExpected : %v\n\tGot      : %v", expected, actual)
+	}
+
+	deck, _ := app.GetDeck("a251071b-662f-44b6-ba11-e24863039c59")
+	if deck.String() != "1L 1L 1L 2L 1T ZK" {
+		t.Errorf("Deck has the wrong cards: %v", deck)
+	}
+
+	actual, _ = DoRequest(t, "POST", "/api/v1/decks/a251071b-662f-44b6-ba11-e24863039c59/draw?count=6")
+	expected = `{"cards":[` +
+		`{"value":"CUB","suite":"LIONS","code":"1L"},{"value":"CUB","suite":"LIONS","code":"1L"},{"value":"CUB","suite":"LIONS","code":"1L"},` +
+		`{"value":"ADULT","suite":"LIONS","code":"2L"},{"value":"CUB","suite":"TIGERS","code":"1T"},{"value":"ZOOKEEPER","code":"ZK"}]}` + "\n"
+	if expected != actual {
+		t.Errorf("Wrong result returned.\n\tExpected : %v\n\tGot      : %v", expected, actual)
+	}
+}
+
+// Custom decks of a defined type are checked against it, rather than the standard deck.
+func TestCustomDeckOfADefinedType(t *testing.T) {
+	name := uniqueDeckTypeName("zoo")
+	DoBodyRequest(t, "POST", "/api/v1/deck-types", zooDefinition(name))
+
+	_, status := DoRequest(t, "POST", "/api/v1/decks?type="+name+"&cards=1L,ZK")
+	if status != http.StatusOK {
+		t.Errorf("Recived wrong status code. Expected %v, got %v.", http.StatusOK, status)
+	}
+
+	for _, cards := range []string{"AS", "2T", "3L"} {
+		_, status = DoRequest(t, "POST", "/api/v1/decks?type="+name+"&cards="+cards)
+		if status != http.StatusBadRequest {
+			t.Errorf("Recived wrong status code for %v. Expected %v, got %v.", cards, http.StatusBadRequest, status)
+		}
+	}
+}
+
+// Names can only be used once, and the built in types can't be replaced.
+func TestDeckTypeNamesAreTaken(t *testing.T) {
+	name := uniqueDeckTypeName("zoo")
+	DoBodyRequest(t, "POST", "/api/v1/deck-types", zooDefinition(name))
+
+	for _, taken := range []string{name, "standard"} {
+		_, status := DoBodyRequest(t, "POST", "/api/v1/deck-types", zooDefinition(taken))
+		if status != http.StatusConflict {
+			t.Errorf("Recived wrong status code redefining %v. Expected %v, got %v.", taken, http.StatusConflict, status)
+		}
+	}
+}
+
+// Definitions that don't make sense are refused.
+func TestInvalidDeckTypeDefinitions(t *testing.T) {
+	name := uniqueDeckTypeName("bad")
+	suites := `"suites": [{"code": "L", "name": "LIONS"}]`
+	ranks := `"ranks": [{"code": "1", "name": "CUB"}]`
+
+	for reason, body := range map[string]string{
+		"not json":             `{"name": `,
+		"unknown field":        `{"name": "` + name + `", ` + suites + `, ` + ranks + `, "colour": "red"}`,
+		"bad name":             `{"name": "Big Cats", ` + suites + `, ` + ranks + `}`,
+		"long suite code":      `{"name": "` + name + `", "suites": [{"code": "LI", "name": "LIONS"}], ` + ranks + `}`,
+		"bad code characters":  `{"name": "` + name + `", ` + suites + `, "ranks": [{"code": "1,", "name": "CUB"}]}`,
+		"duplicate rank":       `{"name": "` + name + `", ` + suites + `, "ranks": [{"code": "1", "name": "CUB"}, {"code": "1", "name": "ADULT"}]}`,
+		"unnamed suite":        `{"name": "` + name + `", "suites": [{"code": "L", "name": ""}], ` + ranks + `}`,
+		"ranks without suites": `{"name": "` + name + `", ` + ranks + `}`,
+		"special clash":        `{"name": "` + name + `", ` + suites + `, ` + ranks + `, "specials": [{"code": "1L", "name": "ODD"}]}`,
+		"unknown count":        `{"name": "` + name + `", ` + suites + `, ` + ranks + `, "counts": {"2L": 1}}`,
+		"negative count":       `{"name": "` + name + `", ` + suites + `, ` + ranks + `, "counts": {"1L": -1}}`,
+		"too many cards":       `{"name": "` + name + `", ` + suites + `, ` + ranks + `, "counts": {"1L": 1001}}`,
+		"no cards":             `{"name": "` + name + `", ` + suites + `, ` + ranks + `, "counts": {"1L": 0}}`,
+	} {
+		actual, status := DoBodyRequest(t, "POST", "/api/v1/deck-types", body)
+		if status != http.StatusBadRequest {
+			t.Errorf("Definition with %v was not refused. Expected %v, got %v: %v", reason, http.StatusBadRequest, status, actual)
+		}
+	}
+
+	if _, ok := app.LookupDeckType(name); ok {
+		t.Error("A refused definition was registered anyway.")
+	}
+}
+
+// Defined deck types are kept in the store, and a new app on the same store can use them straight away.
+func TestDefinedDeckTypesAreStored(t *testing.T) {
+	dir := t.TempDir()
+	store := openFileStore(t, dir)
+
+	name := uniqueDeckTypeName("zoo")
+	definition := toggleDecks.DeckTypeDefinition{
+		Name:   name,
+		Suites: []toggleDecks.NamedCode{{Code: "L", Name: "LIONS"}},
+		Ranks:  []toggleDecks.NamedCode{{Code: "1", Name: "CUB"}},
+	}
+	deckType, err := definition.Build()
+	if err != nil {
+		t.Fatalf("Unable to build the deck type: %v", err)
+	}
+	if err := store.CreateDeckType(deckType); err != nil {
+		t.Fatalf("Unable to store the deck type: %v", err)
+	}
+	_ = store.Close()
+
+	store = openFileStore(t, dir)
+	defer store.Close()
+
+	types := store.ListDeckTypes()
+	if len(types) != 1 || types[0].Name != name || types[0].Cards != "1L" || types[0].Ranks["1"] != "CUB" {
+		t.Fatalf("Deck type did not come back from the store: %v", types)
+	}
+
+	if _, ok := toggleDecks.NewApp(store).LookupDeckType(name); !ok {
+		t.Error("Stored deck type can't be used by the new app.")
+	}
+}
+
+// Deck types belong to the app whose store they are kept in, so apps with different stores don't see each other's.
+func TestDefinedDeckTypesBelongToTheirApp(t *testing.T) {
+	name := uniqueDeckTypeName("zoo")
+	DoBodyRequest(t, "POST", "/api/v1/deck-types", zooDefinition(name))
+
+	other := toggleDecks.NewApp(toggleDecks.NewMemoryDeckStore())
+	if _, ok := other.LookupDeckType(name); ok {
+		t.Error("A deck type defined in one app can be used by another.")
+	}
+
+	for _, listed := range other.DeckTypes() {
+		if listed.Name == name {
+			t.Error("A deck type defined in one app is listed by another.")
+		}
+	}
+
+	if _, _, err := other.StoreNewDeck(toggleDecks.DeckOptions{Type: name}); err != toggleDecks.ErrUnknownDeckType {
+		t.Errorf("A deck was created of another app's deck type.  Expected %v, got %v", toggleDecks.ErrUnknownDeckType, err)
+	}
+
+	if _, ok := app.LookupDeckType(name); !ok {
+		t.Error("The deck type can't be used by the app it was defined in.")
+	}
+}
+
+// A stored deck type that clashes with a built in one, say because the built in type was added later, is skipped,
+// and the app still starts with the built in type.
+func TestStoredDeckTypeClashIsSkipped(t *testing.T) {
+	store := toggleDecks.NewMemoryDeckStore()
+	clash := &toggleDecks.DeckType{Name: "standard", Cards: "1L", Suites: map[string]string{"L": "LIONS"}, Ranks: map[string]string{"1": "CUB"}, UserDefined: true}
+	if err := store.CreateDeckType(clash); err != nil {
+		t.Fatalf("Unable to store the deck type: %v", err)
+	}
+
+	deckType, ok := toggleDecks.NewApp(store).LookupDeckType("standard")
+	if !ok || deckType.UserDefined || deckType.Size() != 52 {
+		t.Errorf("The stored deck type replaced the built in one: %v", deckType)
+	}
+}
+
+// Stores don't have to keep deck types.  Apps using one that doesn't still work, but deck types can't be defined.
+func TestDeckTypesNeedAStoreThatKeepsThem(t *testing.T) {
+	store := &countingStore{DeckStore: toggleDecks.NewMemoryDeckStore()}
+	custom := toggleDecks.NewApp(store)
+
+	req, _ := http.NewRequest("POST", "/api/v1/deck-types", strings.NewReader(zooDefinition(uniqueDeckTypeName("zoo"))))
+	rr := httptest.NewRecorder()
+	custom.Router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotImplemented || !strings.Contains(rr.Body.String(), `"code":"deck_types_not_stored"`) {
+		t.Errorf("Deck type was not refused.  Expected %v, got %v: %v", http.StatusNotImplemented, rr.Code, rr.Body.String())
+	}
+
+	if _, _, err := custom.StoreNewDeck(toggleDecks.DeckOptions{}); err != nil {
+		t.Errorf("Unable to create a deck: %v", err)
+	}
+}
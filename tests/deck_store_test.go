@@ -0,0 +1,185 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/GamalielMasters/toggleDecks"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Test the deck store abstraction.
+
+// The in memory store hands back what you put in it.
+func TestMemoryStoreCreateAndGet(t *testing.T) {
+	store := toggleDecks.NewMemoryDeckStore()
+	deck := toggleDecks.CreateDeck("AS KH")
+
+	if err := store.Create("one", deck); err != nil {
+		t.Fatalf("Unexpected error creating deck: %v", err)
+	}
+
+	got, ok := store.Get("one")
+	if !ok || got != deck {
+		t.Error("Store did not return the deck that was filed.")
+	}
+
+	if _, ok := store.Get("two"); ok {
+		t.Error("Store returned a deck for an ID that was never filed.")
+	}
+}
+
+// But it won't let you file two decks under the same ID.
+func TestMemoryStoreRejectsDuplicateIds(t *testing.T) {
+	store := toggleDecks.NewMemoryDeckStore()
+	first := toggleDecks.CreateDeck("AS")
+	second := toggleDecks.CreateDeck("KH")
+
+	_ = store.Create("one", first)
+	if err := store.Create("one", second); err != toggleDecks.ErrDeckExists {
+		t.Errorf("Expected %v when re-using an ID, got %v", toggleDecks.ErrDeckExists, err)
+	}
+}
+
+// Updating, deleting, listing and clearing all work on the stored decks.
+func TestMemoryStoreUpdateDeleteListClear(t *testing.T) {
+	store := toggleDecks.NewMemoryDeckStore()
+	for i := 0; i < 3; i++ {
+		deck := toggleDecks.CreateFullDeck()
+		_ = store.Create(fmt.Sprint(i), deck)
+	}
+
+	if len(store.List()) != 3 {
+		t.Errorf("Expected 3 decks listed, got %v", len(store.List()))
+	}
+
+	deck, _ := store.Get("0")
+	deck.Draw(2)
+	if err := store.Update("0", deck); err != nil {
+		t.Errorf("Unexpected error updating deck: %v", err)
+	}
+
+	if err := store.Update("missing", deck); err != toggleDecks.ErrDeckNotFound {
+		t.Errorf("Expected %v updating a missing deck, got %v", toggleDecks.ErrDeckNotFound, err)
+	}
+
+	if err := store.Delete("1"); err != nil {
+		t.Errorf("Unexpected error deleting deck: %v", err)
+	}
+
+	if err := store.Delete("1"); err != toggleDecks.ErrDeckNotFound {
+		t.Errorf("Expected %v deleting a deck twice, got %v", toggleDecks.ErrDeckNotFound, err)
+	}
+
+	if len(store.List()) != 2 {
+		t.Errorf("Expected 2 decks listed after a delete, got %v", len(store.List()))
+	}
+
+	_ = store.Clear()
+	if len(store.List()) != 0 {
+		t.Errorf("Expected no decks after clearing, got %v", len(store.List()))
+	}
+}
+
+// A store that counts the calls made to it, to prove the app goes through the store for everything.
+type countingStore struct {
+	toggleDecks.DeckStore
+	creates, gets, updates int
+}
+
+func (s *countingStore) Create(iid string, deck *toggleDecks.Deck) error {
+	s.creates++
+	return s.DeckStore.Create(iid, deck)
+}
+
+func (s *countingStore) Get(iid string) (*toggleDecks.Deck, bool) {
+	s.gets++
+	return s.DeckStore.Get(iid)
+}
+
+func (s *countingStore) Update(iid string, deck *toggleDecks.Deck) error {
+	s.updates++
+	return s.DeckStore.Update(iid, deck)
+}
+
+// Any store can be plugged into the app without touching the REST handlers.
+func TestAppUsesThePluggedInStore(t *testing.T) {
+	store := &countingStore{DeckStore: toggleDecks.NewMemoryDeckStore()}
+	custom := toggleDecks.NewApp(store)
+
+	req, _ := http.NewRequest("POST", "/api/v1/decks", nil)
+	rr := httptest.NewRecorder()
+	custom.Router.ServeHTTP(rr, req)
+
+	ids := store.List()
+	if rr.Code != http.StatusOK || store.creates != 1 || len(ids) != 1 {
+		t.Fatalf("Deck was not created through the store. Status %v, creates %v", rr.Code, store.creates)
+	}
+
+	req, _ = http.NewRequest("POST", fmt.Sprintf("/api/v1/decks/%v/draw?count=2", ids[0]), nil)
+	rr = httptest.NewRecorder()
+	custom.Router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Recived wrong status code. Expected %v, got %v.", http.StatusOK, rr.Code)
+	}
+
+	if store.gets != 1 || store.updates != 1 {
+		t.Errorf("Draw did not go through the store. Gets %v, updates %v", store.gets, store.updates)
+	}
+}
+
+// A store that refuses to record changes to decks while failing is set.
+type failingStore struct {
+	toggleDecks.DeckStore
+	failing bool
+}
+
+func (s *failingStore) Update(iid string, deck *toggleDecks.Deck) error {
+	if s.failing {
+		return errors.New("the disk is full")
+	}
+	return s.DeckStore.Update(iid, deck)
+}
+
+// When a change can't be stored the request fails, and the deck is left as it was, as if the request never happened.
+func TestChangesThatCantBeStoredAreUndone(t *testing.T) {
+	// The clock stands still, so using the deck doesn't change it either.
+	PatchClock()
+	defer UnPatchClock()
+
+	store := &failingStore{DeckStore: toggleDecks.NewMemoryDeckStore()}
+	custom := toggleDecks.NewApp(store)
+
+	iid, deck, _ := custom.StoreNewDeck(toggleDecks.DeckOptions{})
+	_ = deck.CreatePile("hand")
+	_, _ = deck.DrawToPile("hand", 2)
+	before, _ := json.Marshal(deck)
+	store.failing = true
+
+	for _, request := range []struct{ method, url, body string }{
+		{"POST", "/api/v1/decks/%v/draw?count=5", ""},
+		{"POST", "/api/v1/decks/%v/shuffle?mode=all", ""},
+		{"POST", "/api/v1/decks/%v/piles/discard", ""},
+		{"POST", "/api/v1/decks/%v/piles/hand/draw", ""},
+		{"POST", "/api/v1/decks/%v/piles/hand/shuffle", ""},
+		{"PATCH", "/api/v1/decks/%v", `{"labels": {"table": "7"}}`},
+	} {
+		req, _ := http.NewRequest(request.method, fmt.Sprintf(request.url, iid), strings.NewReader(request.body))
+		rr := httptest.NewRecorder()
+		custom.Router.ServeHTTP(rr, req)
+
+		var message toggleDecks.RestErrorMessage
+		_ = json.Unmarshal(rr.Body.Bytes(), &message)
+		if rr.Code != http.StatusInternalServerError || message.Code != toggleDecks.ErrorStorageFailed {
+			t.Errorf("%v %v: expected %v %v, got %v %v", request.method, request.url, http.StatusInternalServerError, toggleDecks.ErrorStorageFailed, rr.Code, message.Code)
+		}
+
+		if after, _ := json.Marshal(deck); string(after) != string(before) {
+			t.Errorf("%v %v changed the deck though it wasn't stored.\n\tBefore: %s\n\tAfter:  %s", request.method, request.url, before, after)
+		}
+	}
+}
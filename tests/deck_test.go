@@ -154,3 +154,94 @@ func TestCannotDrawCardsFromAnExhaustedDeck(t *testing.T) {
 	}
 
 }
+
+// Asking for a negative number of cards draws none, rather than crashing.
+func TestDrawingANegativeNumberOfCardsDrawsNone(t *testing.T) {
+	deck := toggleDecks.CreateFullDeck()
+	cards := deck.Draw(-1)
+
+	if len(cards) != 0 || deck.Len() != 52 {
+		t.Errorf("Drawing -1 cards drew %v, leaving %v.", len(cards), deck.Len())
+	}
+}
+
+// Drawing exactly a number of cards draws nothing if there aren't that many.
+func TestDrawExactlyMoreCardsThanAreLeft(t *testing.T) {
+	deck := toggleDecks.CreateFullDeck()
+	deck.Draw(50)
+
+	if cards, err := deck.DrawExactly(3); err != toggleDecks.ErrNotEnoughCards || len(cards) != 0 || deck.Len() != 2 {
+		t.Errorf("Drawing exactly 3 of 2 cards gave %v and error %v, leaving %v.", cards, err, deck.Len())
+	}
+
+	if cards, err := deck.DrawExactly(2); err != nil || len(cards) != 2 || deck.Len() != 0 {
+		t.Errorf("Drawing exactly the last 2 cards gave %v and error %v, leaving %v.", cards, err, deck.Len())
+	}
+}
+
+// Cards you've drawn can be put back on top of the deck, where they'll be the next ones drawn.
+func TestReturnCardsToTheTopOfTheDeck(t *testing.T) {
+	deck := toggleDecks.CreateDeck("AS KH 8C QD")
+	drawn := deck.Draw(2)
+
+	if err := deck.Return(drawn, toggleDecks.ReturnTop); err != nil {
+		t.Fatalf("Unexpected error returning cards: %v", err)
+	}
+
+	if deck.String() != "AS KH 8C QD" {
+		t.Errorf("Cards were not returned to the top.  Expected 'AS KH 8C QD', got '%v'", deck)
+	}
+}
+
+// Or on the bottom.
+func TestReturnCardsToTheBottomOfTheDeck(t *testing.T) {
+	deck := toggleDecks.CreateDeck("AS KH 8C QD")
+	deck.Draw(2)
+
+	if err := deck.Return([]toggleDecks.Card{"KH"}, toggleDecks.ReturnBottom); err != nil {
+		t.Fatalf("Unexpected error returning cards: %v", err)
+	}
+
+	if deck.String() != "8C QD KH" {
+		t.Errorf("Card was not returned to the bottom.  Expected '8C QD KH', got '%v'", deck)
+	}
+}
+
+// Or anywhere at all.
+func TestReturnCardsToRandomPlacesInTheDeck(t *testing.T) {
+	deck := toggleDecks.CreateFullDeck()
+	drawn := deck.Draw(10)
+
+	if err := deck.Return(drawn, toggleDecks.ReturnRandom); err != nil {
+		t.Fatalf("Unexpected error returning cards: %v", err)
+	}
+
+	if equal, expected, got := DeckContainsCards(deck, toggleDecks.STANDARD_DECK); !equal {
+		t.Errorf("Deck does not contain the proper cards.\n\tExpected: '%v'\n\tGot:      '%v'", expected, got)
+	}
+}
+
+// But you can only put back cards that came out of the deck in the first place.
+func TestCannotReturnCardsThatWereNotDrawn(t *testing.T) {
+	deck := toggleDecks.CreateDeck("AS KH 8C")
+	deck.Draw(1)
+
+	// Never in the deck at all.
+	if err := deck.Return([]toggleDecks.Card{"QD"}, toggleDecks.ReturnTop); err == nil {
+		t.Error("Returned a card that was never in the deck.")
+	}
+
+	// Still in the deck.
+	if err := deck.Return([]toggleDecks.Card{"KH"}, toggleDecks.ReturnTop); err == nil {
+		t.Error("Returned a card that had not been drawn.")
+	}
+
+	// Drawn, but only once.
+	if err := deck.Return([]toggleDecks.Card{"AS", "AS"}, toggleDecks.ReturnTop); err == nil {
+		t.Error("Returned the same drawn card twice.")
+	}
+
+	if deck.String() != "KH 8C" {
+		t.Errorf("A failed return changed the deck.  Expected 'KH 8C', got '%v'", deck)
+	}
+}
@@ -0,0 +1,134 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/GamalielMasters/toggleDecks"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// Test the types of deck beyond the standard one.
+
+// Every deck type has the number of cards it should, and they all have names.
+func TestDeckTypeSizes(t *testing.T) {
+	sizes := map[string]int{"standard": 52, "piquet": 32, "euchre": 24, "pinochle": 48, "spanish-40": 40, "spanish-48": 48, "german": 32, "tarot": 78}
+
+	for name, size := range sizes {
+		deckType, ok := toggleDecks.LookupDeckType(name)
+		if !ok {
+			t.Errorf("Deck type %v is missing.", name)
+			continue
+		}
+
+		if deckType.Size() != size {
+			t.Errorf("A %v deck should have %v cards, not %v.", name, size, deckType.Size())
+		}
+
+		for _, code := range strings.Fields(deckType.Cards) {
+			if c := toggleDecks.Card(code); deckType.Rank(c) == "" || (deckType.Suite(c) == "" && deckType.Specials[code] == "") {
+				t.Errorf("Card %v of a %v deck has no name.", code, name)
+			}
+		}
+	}
+}
+
+// Pinochle decks have two of every card.
+func TestPinochleHasDuplicates(t *testing.T) {
+	deckType, _ := toggleDecks.LookupDeckType("pinochle")
+	if strings.Count(deckType.Cards, "QS") != 2 || strings.Count(deckType.Cards, "9H") != 2 || strings.Contains(deckType.Cards, "8") {
+		t.Errorf("Wrong pinochle deck: %v", deckType.Cards)
+	}
+}
+
+// The list of deck types says what each is made of.
+func TestListDeckTypes(t *testing.T) {
+	actual, status := DoRequest(t, "GET", "/api/v1/deck-types")
+
+	if status != http.StatusOK {
+		t.Errorf("Recived wrong status code. Expected %v, got %v.", http.StatusOK, status)
+	}
+
+	var message toggleDecks.RestDeckTypeListMessage
+	if err := json.Unmarshal([]byte(actual), &message); err != nil {
+		t.Fatalf("Unable to decode the deck types %v: %v", actual, err)
+	}
+
+	var names []string
+	for _, deckType := range message.DeckTypes {
+		if !deckType.UserDefined {
+			names = append(names, deckType.Name)
+		}
+		if deckType.Size != len(deckType.Cards) {
+			t.Errorf("Deck type %v says it has %v cards, but lists %v.", deckType.Name, deckType.Size, len(deckType.Cards))
+		}
+	}
+
+	// Leaving out any defined by other tests.
+	expected := "euchre german pinochle piquet spanish-40 spanish-48 standard tarot"
+	if strings.Join(names, " ") != expected {
+		t.Errorf("Wrong deck types listed.\n\tExpected: %v\n\tGot:      %v", expected, strings.Join(names, " "))
+	}
+}
+
+// Decks of other types are named in their own vocabulary, and say what type they are.
+func TestCreateSpanishDeck(t *testing.T) {
+	actual, status := DoCreateRequest(t, "POST", "/api/v1/decks?type=spanish-40")
+
+	if status != http.StatusOK {
+		t.Errorf("Recived wrong status code. Expected %v, got %v.", http.StatusOK, status)
+	}
+
+	expected := `{"deck_id":"a251071b-662f-44b6-ba11-e24863039c59","shuffled":false,"remaining":40,"version":0,"type":"spanish-40"}` + "\n"
+	if expected != actual {
+		t.Errorf("Wrong result returned.\n\tExpected : %v\n\tGot      : %v", expected, actual)
+	}
+
+	actual, _ = DoRequest(t, "POST", "/api/v1/decks/a251071b-662f-44b6-ba11-e24863039c59/draw?count=2")
+	expected = `{"cards":[{"value":"ACE","suite":"COINS","code":"1O"},{"value":"2","suite":"COINS","code":"2O"}]}` + "\n"
+	if expected != actual {
+		t.Errorf("Wrong result returned.\n\tExpected : %v\n\tGot      : %v", expected, actual)
+	}
+}
+
+// Tarot trumps have the trump suite and are only numbered, even the first, and the Excuse has no suite.
+func TestTarotTrumpsAndTheExcuse(t *testing.T) {
+	DoCreateRequest(t, "POST", "/api/v1/decks?type=tarot&cards=21T,1T,EX,CH,1H")
+
+	actual, _ := DoRequest(t, "POST", "/api/v1/decks/a251071b-662f-44b6-ba11-e24863039c59/draw?count=5")
+	expected := `{"cards":[{"value":"21","suite":"TRUMPS","code":"21T"},{"value":"1","suite":"TRUMPS","code":"1T"},{"value":"THE EXCUSE","code":"EX"},` +
+		`{"value":"KNIGHT","suite":"HEARTS","code":"CH"},{"value":"ACE","suite":"HEARTS","code":"1H"}]}` + "\n"
+	if expected != actual {
+		t.Errorf("Wrong result returned.\n\tExpected : %v\n\tGot      : %v", expected, actual)
+	}
+}
+
+// Custom decks can only have cards that are in the type of deck, and only deck types that exist can be created.
+func TestCreateDeckTypeRefusals(t *testing.T) {
+	for _, url := range []string{
+		"/api/v1/decks?type=tarot&cards=15S",
+		"/api/v1/decks?type=tarot&cards=JT",
+		"/api/v1/decks?type=german&cards=QH",
+		"/api/v1/decks?type=euchre&cards=2S",
+		"/api/v1/decks?type=uno",
+		"/api/v1/decks?type=spanish-48&jokers_enabled=true",
+	} {
+		actual, status := DoRequest(t, "POST", url)
+		if status != http.StatusBadRequest {
+			t.Errorf("Recived wrong status code for %v. Expected %v, got %v: %v", url, http.StatusBadRequest, status, actual)
+		}
+	}
+}
+
+// Cards returned to a deck are checked against its own type.
+func TestReturnToATypedDeck(t *testing.T) {
+	DoCreateRequest(t, "POST", "/api/v1/decks?type=german")
+	iid := "a251071b-662f-44b6-ba11-e24863039c59"
+	DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/draw", iid))
+
+	_, status := DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/return?cards=7E", iid))
+	if status != http.StatusOK {
+		t.Errorf("Recived wrong status code. Expected %v, got %v.", http.StatusOK, status)
+	}
+}
@@ -0,0 +1,90 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// When you're done with a deck, you can throw it away.
+func TestDeleteADeck(t *testing.T) {
+	iid := app.NewDeck("AS KH 8C", false)
+
+	actual, status := DoRequest(t, "DELETE", fmt.Sprintf("/api/v1/decks/%v", iid))
+
+	if status != http.StatusNoContent {
+		t.Errorf("Recived wrong status code. Expected %v, got %v.", http.StatusNoContent, status)
+	}
+
+	if actual != "" {
+		t.Errorf("Expected an empty body, got %v", actual)
+	}
+
+	if _, ok := app.GetDeck(iid); ok {
+		t.Error("Deck is still in the database after being deleted.")
+	}
+
+	_, status = DoRequest(t, "GET", fmt.Sprintf("/api/v1/decks/%v", iid))
+	if status != http.StatusNotFound {
+		t.Errorf("Deleted deck can still be opened. Expected %v, got %v.", http.StatusNotFound, status)
+	}
+}
+
+// But you can't throw away a deck you don't have.
+func TestDeleteANonExistentDeck(t *testing.T) {
+	_, status := DoRequest(t, "DELETE", "/api/v1/decks/f6d6ccf0-b740-459d-9e90-4b3869e1985c")
+
+	if status != http.StatusNotFound {
+		t.Errorf("Recived wrong status code. Expected %v, got %v.", http.StatusNotFound, status)
+	}
+}
+
+// You can throw away a whole stack of decks at once.
+func TestBulkDeleteDecks(t *testing.T) {
+	app.ClearTheDatabase()
+	first := app.NewDeck("", false)
+	second := app.NewDeck("", true)
+	kept := app.NewDeck("AS", false)
+
+	_, status := DoRequest(t, "DELETE", fmt.Sprintf("/api/v1/decks?ids=%v,%v", first, second))
+
+	if status != http.StatusNoContent {
+		t.Errorf("Recived wrong status code. Expected %v, got %v.", http.StatusNoContent, status)
+	}
+
+	if ids := app.Store.List(); len(ids) != 1 || ids[0] != kept {
+		t.Errorf("Expected only deck %v to be left, got %v", kept, ids)
+	}
+}
+
+// But if any of them don't exist, none of them are thrown away.
+func TestBulkDeleteWithAnUnknownDeck(t *testing.T) {
+	app.ClearTheDatabase()
+	first := app.NewDeck("", false)
+
+	_, status := DoRequest(t, "DELETE", fmt.Sprintf("/api/v1/decks?ids=%v,INVALID_ID", first))
+
+	if status != http.StatusNotFound {
+		t.Errorf("Recived wrong status code. Expected %v, got %v.", http.StatusNotFound, status)
+	}
+
+	if _, ok := app.GetDeck(first); !ok {
+		t.Error("Deck was deleted even though the request failed.")
+	}
+}
+
+// And you have to say which decks you want thrown away.
+func TestBulkDeleteWithNoIds(t *testing.T) {
+	app.ClearTheDatabase()
+	app.NewDeck("", false)
+
+	_, status := DoRequest(t, "DELETE", "/api/v1/decks")
+
+	if status != http.StatusBadRequest {
+		t.Errorf("Recived wrong status code. Expected %v, got %v.", http.StatusBadRequest, status)
+	}
+
+	if len(app.Store.List()) != 1 {
+		t.Error("Decks were deleted without being asked for.")
+	}
+}
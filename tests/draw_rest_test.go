@@ -3,6 +3,7 @@ package tests
 import (
 	"fmt"
 	"net/http"
+	"strings"
 	"testing"
 )
 
@@ -72,21 +73,58 @@ func TestDrawCardFromAnExhaustedDeck(t *testing.T) {
 	}
 }
 
-// If you provide anything but an integer for the number to draw, you get the default of one card.
-func TestDrawNaNCardsFromADeck(t *testing.T) {
+// If you provide anything but a sensible number of cards to draw, you get an error, and no cards are drawn.
+func TestDrawBadCountsFromADeck(t *testing.T) {
 	iid := app.NewDeck("", false)
-	actual, status := DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/draw?count=NaN", iid))
+	for _, count := range []string{"NaN", "-1", "0", "1.5", "16001", "99999999999999999999", "count=2&number=3"} {
+		query := "count=" + count
+		if strings.Contains(count, "=") {
+			query = count
+		}
+
+		actual, status := DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/draw?%v", iid, query))
+		if status != http.StatusBadRequest || !strings.Contains(actual, `"code":"bad_count"`) {
+			t.Errorf("Drawing %v cards was not refused.  Got %v %v", count, status, actual)
+		}
+	}
+
+	deck, _ := app.GetDeck(iid)
+	if remaining := len(deck.Cards); remaining != 52 {
+		t.Errorf("Cards were drawn by refused requests.  %v are left.", remaining)
+	}
+}
+
+// The count can also be given as number, as it used to be documented.
+func TestDrawCountGivenAsNumber(t *testing.T) {
+	iid := app.NewDeck("", false)
+	actual, status := DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/draw?number=2", iid))
 
 	if status != http.StatusOK {
 		t.Errorf("Recived wrong status code. Expected %v, got %v.", http.StatusOK, status)
 	}
 
-	expected := `{"cards":[{"value":"ACE","suite":"SPADES","code":"AS"}]}` + "\n"
+	expected := `{"cards":[{"value":"ACE","suite":"SPADES","code":"AS"},{"value":"2","suite":"SPADES","code":"2S"}]}` + "\n"
 	if expected != actual {
 		t.Errorf("Wrong result returned.\n\tExpected : %v\n\tGot      : %v", expected, actual)
 	}
 }
 
+// A strict draw of more cards than are left fails, and leaves the deck alone.
+func TestStrictDrawOfTooManyCards(t *testing.T) {
+	iid := app.NewDeck("AS KH", false)
+	actual, status := DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/draw?count=3&strict=true", iid))
+
+	if status != http.StatusConflict || !strings.Contains(actual, `"code":"not_enough_cards"`) {
+		t.Errorf("A strict draw of too many cards did not fail.  Got %v %v", status, actual)
+	}
+
+	actual, status = DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/draw?count=2&strict=true", iid))
+	expected := `{"cards":[{"value":"ACE","suite":"SPADES","code":"AS"},{"value":"KING","suite":"HEARTS","code":"KH"}]}` + "\n"
+	if status != http.StatusOK || expected != actual {
+		t.Errorf("A strict draw of every card did not work.  Got %v %v", status, actual)
+	}
+}
+
 // Trying to draw cards from an invalid deck id gives you a big fat 404, good buddy!
 func TestDrawCardsFromAnInvalidDeck(t *testing.T) {
 	_, status := DoRequest(t, "POST", "/api/v1/decks/INVALID_ID/draw?count=1")
@@ -0,0 +1,72 @@
+package tests
+
+import (
+	"encoding/json"
+	"github.com/GamalielMasters/toggleDecks"
+	"net/http"
+	"testing"
+)
+
+// Test the error envelope every failed request is answered with.
+
+// Errors carry a code, a message, and the id the client gave the request.
+func TestErrorsAreEnveloped(t *testing.T) {
+	rr := DoHeaderRequest(t, "GET", "/api/v1/decks/no-such-deck", "", map[string]string{toggleDecks.RequestIdHeader: "client-id-1"})
+	message := CheckErrorResponse(t, rr, http.StatusNotFound, toggleDecks.ErrorDeckNotFound)
+
+	if len(message.Message) == 0 {
+		t.Error("The error has no message.")
+	}
+
+	if message.RequestId != "client-id-1" || rr.Header().Get(toggleDecks.RequestIdHeader) != "client-id-1" {
+		t.Errorf("The client's request id was not used.  Got %q and header %q", message.RequestId, rr.Header().Get(toggleDecks.RequestIdHeader))
+	}
+}
+
+// Requests without a usable id are given one.
+func TestErrorsGetARequestId(t *testing.T) {
+	toggleDecks.TheRequestIdProvider = GuidMock{}
+	defer func() { toggleDecks.TheRequestIdProvider = toggleDecks.GuidIdProvider{} }()
+
+	for _, requestId := range []string{"", "bad id\r\nX-Injected: 1"} {
+		rr := DoHeaderRequest(t, "POST", "/api/v1/decks?deck_count=0", "", map[string]string{toggleDecks.RequestIdHeader: requestId})
+		message := CheckErrorResponse(t, rr, http.StatusBadRequest, toggleDecks.ErrorBadCount)
+
+		if expected := (GuidMock{}).GenerateIdentifier(); message.RequestId != expected {
+			t.Errorf("Request %q was not given an id.  Expected %v but got %q", requestId, expected, message.RequestId)
+		}
+	}
+}
+
+// Missing decks are listed in the details when deleting several.
+func TestBulkDeleteErrorDetailsMissingDecks(t *testing.T) {
+	app.ClearTheDatabase()
+	iid := app.NewDeck("AS", false)
+
+	rr := DoHeaderRequest(t, "DELETE", "/api/v1/decks?ids="+iid+",gone-1,gone-2", "", nil)
+
+	var message struct {
+		Code    toggleDecks.ErrorCode `json:"code"`
+		Details []string              `json:"details"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &message); err != nil {
+		t.Fatalf("The error was not a JSON envelope: %v\n\t%v", err, rr.Body.String())
+	}
+
+	if message.Code != toggleDecks.ErrorDeckNotFound || len(message.Details) != 2 || message.Details[0] != "gone-1" || message.Details[1] != "gone-2" {
+		t.Errorf("The missing decks were not detailed.  Got %v %v", message.Code, message.Details)
+	}
+}
+
+// The router's own errors, for unknown paths and methods, use the envelope too.
+func TestRouterErrorsAreEnveloped(t *testing.T) {
+	message := CheckErrorResponse(t, DoHeaderRequest(t, "GET", "/api/v1/nothing-here", "", nil), http.StatusNotFound, toggleDecks.ErrorNotFound)
+	if len(message.RequestId) == 0 {
+		t.Error("The error for an unknown path has no request id.")
+	}
+
+	message = CheckErrorResponse(t, DoHeaderRequest(t, "PUT", "/api/v1/decks", "", nil), http.StatusMethodNotAllowed, toggleDecks.ErrorMethodNotAllowed)
+	if len(message.RequestId) == 0 {
+		t.Error("The error for an unknown method has no request id.")
+	}
+}
@@ -0,0 +1,175 @@
+package tests
+
+import (
+	"fmt"
+	"github.com/GamalielMasters/toggleDecks"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Test the expiry of idle decks.
+
+// Without an idle TTL, decks live forever.
+func TestDecksWithoutATTLNeverExpire(t *testing.T) {
+	clock := PatchClock()
+	defer UnPatchClock()
+	app.ClearTheDatabase()
+
+	iid := app.NewDeck("", false)
+	clock.Advance(365 * 24 * time.Hour)
+
+	if reaped := app.ReapExpiredDecks(); reaped != 0 {
+		t.Errorf("Expected no decks to be reaped, got %v", reaped)
+	}
+
+	if _, ok := app.GetDeck(iid); !ok {
+		t.Error("Deck was removed even though decks don't expire.")
+	}
+}
+
+// With one, a deck left alone for longer than the TTL is reaped, but not before.
+func TestIdleDecksAreReaped(t *testing.T) {
+	clock := PatchClock()
+	defer UnPatchClock()
+	app.ClearTheDatabase()
+	app.IdleTTL = time.Hour
+	defer func() { app.IdleTTL = 0 }()
+
+	iid := app.NewDeck("", false)
+
+	clock.Advance(59 * time.Minute)
+	if reaped := app.ReapExpiredDecks(); reaped != 0 {
+		t.Errorf("Deck was reaped before its TTL was up.")
+	}
+
+	clock.Advance(2 * time.Minute)
+	if reaped := app.ReapExpiredDecks(); reaped != 1 {
+		t.Errorf("Expected the idle deck to be reaped, got %v reaped", reaped)
+	}
+
+	if _, ok := app.GetDeck(iid); ok {
+		t.Error("Reaped deck is still in the database.")
+	}
+}
+
+// Using a deck keeps it alive.
+func TestUsingADeckResetsItsExpiry(t *testing.T) {
+	clock := PatchClock()
+	defer UnPatchClock()
+	app.ClearTheDatabase()
+	app.IdleTTL = time.Hour
+	defer func() { app.IdleTTL = 0 }()
+
+	opened := app.NewDeck("", false)
+	drawn := app.NewDeck("", false)
+
+	clock.Advance(45 * time.Minute)
+	DoRequest(t, "GET", fmt.Sprintf("/api/v1/decks/%v", opened))
+	DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/draw", drawn))
+
+	clock.Advance(45 * time.Minute)
+	if reaped := app.ReapExpiredDecks(); reaped != 0 {
+		t.Errorf("Decks that were used were reaped anyway.")
+	}
+}
+
+// When decks expire, their details say when.
+func TestDeckDetailsIncludeExpiry(t *testing.T) {
+	clock := PatchClock()
+	defer UnPatchClock()
+	app.IdleTTL = time.Hour
+	defer func() { app.IdleTTL = 0 }()
+
+	iid := app.NewDeck("AS", false)
+	clock.Advance(10 * time.Minute)
+
+	actual, status := DoRequest(t, "GET", fmt.Sprintf("/api/v1/decks/%v", iid))
+
+	if status != http.StatusOK {
+		t.Errorf("Recived wrong status code. Expected %v, got %v.", http.StatusOK, status)
+	}
+
+	expected := fmt.Sprintf(`{"deck_id":"%v","shuffled":false,"remaining":1,"version":0,"cards":[{"value":"ACE","suite":"SPADES","code":"AS"}],"expires_at":"2020-01-01T13:10:00Z"}`+"\n", iid)
+	if expected != actual {
+		t.Errorf("Wrong result returned.\n\tExpected : %v\n\tGot      : %v", expected, actual)
+	}
+}
+
+// A deck that has expired is gone, even if the reaper hasn't got to it yet.
+func TestExpiredDeckCannotBeOpened(t *testing.T) {
+	clock := PatchClock()
+	defer UnPatchClock()
+	app.IdleTTL = time.Hour
+	defer func() { app.IdleTTL = 0 }()
+
+	iid := app.NewDeck("", false)
+	clock.Advance(2 * time.Hour)
+
+	_, status := DoRequest(t, "GET", fmt.Sprintf("/api/v1/decks/%v", iid))
+	if status != http.StatusNotFound {
+		t.Errorf("Recived wrong status code. Expected %v, got %v.", http.StatusNotFound, status)
+	}
+
+	if _, ok := app.GetDeck(iid); ok {
+		t.Error("Expired deck was left in the database.")
+	}
+}
+
+// The background reaper does the reaping all by itself.
+func TestBackgroundReaper(t *testing.T) {
+	clock := PatchClock()
+	defer UnPatchClock()
+	app.ClearTheDatabase()
+	app.IdleTTL = time.Hour
+	defer func() { app.IdleTTL = 0 }()
+
+	stale := app.NewDeck("", false)
+	stop := app.StartReaper(time.Minute)
+
+	clock.Advance(30 * time.Minute)
+	fresh := app.NewDeck("", false)
+	clock.Advance(31 * time.Minute)
+
+	// Stopping waits for the reap started by the last tick to finish.
+	stop()
+
+	if _, ok := app.GetDeck(stale); ok {
+		t.Error("The reaper did not remove the idle deck.")
+	}
+
+	if _, ok := app.GetDeck(fresh); !ok {
+		t.Error("The reaper removed a deck that had not expired.")
+	}
+}
+
+// Reading a deck keeps it alive without writing it to the store; the reaper stores the new access time once.
+func TestReadingADeckDoesNotStoreIt(t *testing.T) {
+	clock := PatchClock()
+	defer UnPatchClock()
+
+	store := &countingStore{DeckStore: toggleDecks.NewMemoryDeckStore()}
+	custom := toggleDecks.NewApp(store)
+	custom.IdleTTL = time.Hour
+	iid, deck, _ := custom.StoreNewDeck(toggleDecks.DeckOptions{})
+
+	clock.Advance(30 * time.Minute)
+	for _, url := range []string{"/api/v1/decks/%v", "/api/v1/decks/%v/history", "/api/v1/decks/%v/piles"} {
+		req, _ := http.NewRequest("GET", fmt.Sprintf(url, iid), nil)
+		custom.Router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if store.updates != 0 {
+		t.Errorf("Reading the deck stored it %v times.", store.updates)
+	}
+	if expected := clock.Now().Add(time.Hour); !deck.ExpiresAt(time.Hour).Equal(expected) {
+		t.Errorf("Reading the deck did not keep it alive.  Expected it to expire at %v, not %v", expected, deck.ExpiresAt(time.Hour))
+	}
+
+	custom.ReapExpiredDecks()
+	custom.ReapExpiredDecks()
+	if store.updates != 1 {
+		t.Errorf("The reaper should store the access time once, not %v times.", store.updates)
+	}
+}
@@ -0,0 +1,212 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/GamalielMasters/toggleDecks"
+	"net/http"
+	"testing"
+)
+
+// Test provably fair, commit and reveal, shuffles.
+
+// Decode the details of a deck from a response, failing the test if they can't be.
+func decodeDeckMessage(t *testing.T, body string) (message toggleDecks.RestDeckMessage) {
+	if err := json.Unmarshal([]byte(body), &message); err != nil {
+		t.Fatalf("Unable to decode the deck details %v: %v", body, err)
+	}
+	return
+}
+
+// The fair shuffle is exactly as documented, so anyone can recompute it.  These values were checked against an
+// independent implementation written from the package documentation.
+func TestFairOrderKnownAnswer(t *testing.T) {
+	cards := toggleDecks.CreateDeck("AS KH 8C QD 2S JH").Cards
+
+	order := toggleDecks.FairOrder("server seed", "client seed", cards)
+	if fmt.Sprint(order) != "[2S 8C JH AS QD KH]" {
+		t.Errorf("Wrong order. Expected [2S 8C JH AS QD KH], got %v", order)
+	}
+
+	if hash := toggleDecks.HashServerSeed("server seed"); hash != "a4e53dc2f480b8fce6fe688b1317658b446299df23ad533394406427c8c19557" {
+		t.Errorf("Wrong server seed hash %v", hash)
+	}
+
+	if commitment := toggleDecks.CommitOrder("server seed", order); commitment != "9a4a9e3385af6572d72883f0c53951b357f38b401f08e7ddbd65573b2991ba3a" {
+		t.Errorf("Wrong commitment %v", commitment)
+	}
+
+	if fmt.Sprint(cards) != "[AS KH 8C QD 2S JH]" {
+		t.Errorf("FairOrder changed the cards it was given: %v", cards)
+	}
+}
+
+// Verification catches a server that swaps its seed, or deals an order other than the one it committed to.
+func TestVerifyFairShuffleCatchesCheating(t *testing.T) {
+	cards := toggleDecks.CreateDeck("AS KH 8C QD 2S JH").Cards
+	hash := toggleDecks.HashServerSeed("server seed")
+	commitment := toggleDecks.CommitOrder("server seed", toggleDecks.FairOrder("server seed", "client seed", cards))
+
+	if _, err := toggleDecks.VerifyFairShuffle("server seed", hash, "client seed", commitment, cards); err != nil {
+		t.Errorf("Honest shuffle failed verification: %v", err)
+	}
+
+	if _, err := toggleDecks.VerifyFairShuffle("other seed", hash, "client seed", commitment, cards); err != toggleDecks.ErrServerSeedMismatch {
+		t.Errorf("Swapped server seed was not caught, got %v", err)
+	}
+
+	if _, err := toggleDecks.VerifyFairShuffle("server seed", hash, "other client", commitment, cards); err != toggleDecks.ErrCommitmentMismatch {
+		t.Errorf("Order from another client seed was not caught, got %v", err)
+	}
+}
+
+// A fair deck shuffled at creation publishes its commitments but not its server seed, which is revealed once the last
+// card is drawn.  The revealed seed then proves the order the cards were dealt in.
+func TestFairDeckIsRevealedWhenExhausted(t *testing.T) {
+	body, status := DoCreateRequest(t, "POST", "/api/v1/decks?shuffle=true&shuffle_mode=fair&client_seed=lucky&cards=AS,KH,8C,QD,2S")
+	if status != http.StatusOK {
+		t.Fatalf("Recived wrong status code. Expected %v, got %v.", http.StatusOK, status)
+	}
+
+	created := decodeDeckMessage(t, body)
+	if created.Fair == nil || created.Fair.ServerSeedHash == "" || created.Fair.Commitment == "" || created.Fair.ClientSeed != "lucky" {
+		t.Fatalf("Fair deck did not publish its commitments: %v", body)
+	}
+	if created.Fair.ServerSeed != "" || created.Seed != nil {
+		t.Errorf("Fair deck gave away its seed before it was done: %v", body)
+	}
+
+	iid := created.Id
+	body, _ = DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/draw?count=4", iid))
+	var drawn toggleDecks.RestDrawMessage
+	_ = json.Unmarshal([]byte(body), &drawn)
+
+	opened := decodeDeckMessage(t, mustGet(t, fmt.Sprintf("/api/v1/decks/%v", iid)))
+	if opened.Fair.ServerSeed != "" {
+		t.Error("Server seed was revealed while there were still cards to draw.")
+	}
+
+	body, _ = DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/draw", iid))
+	var last toggleDecks.RestDrawMessage
+	_ = json.Unmarshal([]byte(body), &last)
+	drawn.Cards = append(drawn.Cards, last.Cards...)
+
+	opened = decodeDeckMessage(t, mustGet(t, fmt.Sprintf("/api/v1/decks/%v", iid)))
+	if opened.Fair.ServerSeed == "" {
+		t.Fatal("Server seed was not revealed once the deck was exhausted.")
+	}
+
+	order, err := toggleDecks.VerifyFairShuffle(opened.Fair.ServerSeed, created.Fair.ServerSeedHash, "lucky", created.Fair.Commitment, toggleDecks.CreateDeck("AS KH 8C QD 2S").Cards)
+	if err != nil {
+		t.Fatalf("Revealed shuffle failed verification: %v", err)
+	}
+
+	for i, c := range order {
+		if drawn.Cards[i].Code != c.Code() {
+			t.Errorf("Cards were not dealt in the committed order. Expected %v, got %v", order, drawn.Cards)
+			break
+		}
+	}
+}
+
+// The server seed can be committed to before the players pick their client seed, by creating the deck unshuffled.
+// The one shuffle it gets uses their seed, and closing the deck reveals the server seed.
+func TestFairDeckShuffledWithAClientSeedAndClosed(t *testing.T) {
+	body, _ := DoCreateRequest(t, "POST", "/api/v1/decks?shuffle_mode=fair")
+	created := decodeDeckMessage(t, body)
+	if created.Fair == nil || created.Fair.ServerSeedHash == "" || created.Fair.Commitment != "" || *created.Shuffled {
+		t.Fatalf("Unshuffled fair deck should publish only its server seed hash: %v", body)
+	}
+
+	iid := created.Id
+	body, status := DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/shuffle?client_seed=from-the-players", iid))
+	if status != http.StatusOK {
+		t.Fatalf("Recived wrong status code. Expected %v, got %v.", http.StatusOK, status)
+	}
+	shuffled := decodeDeckMessage(t, body)
+
+	_, status = DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/shuffle", iid))
+	if status != http.StatusConflict {
+		t.Errorf("Fair deck was shuffled twice. Expected %v, got %v.", http.StatusConflict, status)
+	}
+
+	body, status = DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/close", iid))
+	if status != http.StatusOK {
+		t.Fatalf("Recived wrong status code closing. Expected %v, got %v.", http.StatusOK, status)
+	}
+	closed := decodeDeckMessage(t, body)
+
+	order, err := toggleDecks.VerifyFairShuffle(closed.Fair.ServerSeed, created.Fair.ServerSeedHash, "from-the-players", shuffled.Fair.Commitment, toggleDecks.CreateFullDeck().Cards)
+	if err != nil {
+		t.Fatalf("Revealed shuffle failed verification: %v", err)
+	}
+
+	deck, _ := app.GetDeck(iid)
+	if fmt.Sprint(order) != fmt.Sprint(deck.Cards) {
+		t.Errorf("Deck is not in the committed order.\n\tExpected: %v\n\tGot:      %v", order, deck.Cards)
+	}
+}
+
+// Fair decks can't be given a seed, and only fair decks can be closed.
+func TestFairDeckRefusals(t *testing.T) {
+	_, status := DoRequest(t, "POST", "/api/v1/decks?shuffle_mode=fair&seed=42")
+	if status != http.StatusBadRequest {
+		t.Errorf("Recived wrong status code for a seeded fair deck. Expected %v, got %v.", http.StatusBadRequest, status)
+	}
+
+	iid := app.NewDeck("", true)
+	_, status = DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/close", iid))
+	if status != http.StatusBadRequest {
+		t.Errorf("Recived wrong status code closing an ordinary deck. Expected %v, got %v.", http.StatusBadRequest, status)
+	}
+}
+
+// Nothing is dealt from a fair deck until there is a commitment to its order, so no hand dealt can be gathered back
+// by the shuffle.
+func TestUnshuffledFairDeckDoesNotDeal(t *testing.T) {
+	body, _ := DoCreateRequest(t, "POST", "/api/v1/decks?shuffle_mode=fair")
+	iid := decodeDeckMessage(t, body).Id
+	DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/piles/hand", iid))
+
+	for _, url := range []string{"/api/v1/decks/%v/draw", "/api/v1/decks/%v/draw?strict=true", "/api/v1/decks/%v/piles/hand/draw"} {
+		CheckErrorResponse(t, DoHeaderRequest(t, "POST", fmt.Sprintf(url, iid), "", nil), http.StatusConflict, toggleDecks.ErrorFairDeckNotShuffled)
+	}
+
+	DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/shuffle", iid))
+	if _, status := DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/draw", iid)); status != http.StatusOK {
+		t.Errorf("The shuffled fair deck did not deal. Expected %v, got %v.", http.StatusOK, status)
+	}
+}
+
+// Once a fair deck is closed anyone can work out the order of the cards left, so nothing more is dealt from it, and
+// nothing returned to it.
+func TestClosedFairDeckDoesNotDeal(t *testing.T) {
+	body, _ := DoCreateRequest(t, "POST", "/api/v1/decks?shuffle_mode=fair&shuffle=true")
+	iid := decodeDeckMessage(t, body).Id
+	DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/piles/hand", iid))
+	body, _ = DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/draw", iid))
+	var drawn toggleDecks.RestDrawMessage
+	_ = json.Unmarshal([]byte(body), &drawn)
+
+	if _, status := DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/close", iid)); status != http.StatusOK {
+		t.Fatalf("Recived wrong status code closing. Expected %v, got %v.", http.StatusOK, status)
+	}
+
+	for _, url := range []string{"/api/v1/decks/%v/draw", "/api/v1/decks/%v/piles/hand/draw", "/api/v1/decks/%v/return?cards=" + drawn.Cards[0].Code} {
+		CheckErrorResponse(t, DoHeaderRequest(t, "POST", fmt.Sprintf(url, iid), "", nil), http.StatusConflict, toggleDecks.ErrorFairDeckClosed)
+	}
+
+	deck, _ := app.GetDeck(iid)
+	if deck.Len() != 51 {
+		t.Errorf("The closed deck changed.  Expected 51 cards, got %v", deck.Len())
+	}
+}
+
+// Get a url, failing the test if it doesn't work.
+func mustGet(t *testing.T, url string) string {
+	body, status := DoRequest(t, "GET", url)
+	if status != http.StatusOK {
+		t.Fatalf("Recived wrong status code from %v. Expected %v, got %v.", url, http.StatusOK, status)
+	}
+	return body
+}
@@ -0,0 +1,258 @@
+package tests
+
+import (
+	"fmt"
+	"github.com/GamalielMasters/toggleDecks"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Test the file backed deck store.
+
+// Open a file store in the passed directory, failing the test if it can't be opened.
+func openFileStore(t *testing.T, dir string) *toggleDecks.FileDeckStore {
+	store, err := toggleDecks.NewFileDeckStore(dir)
+	if err != nil {
+		t.Fatalf("Unable to open the file store: %v", err)
+	}
+	return store
+}
+
+// Decks written to the store are still there when it is opened again, exactly as they were left.
+func TestFileStoreSurvivesReopening(t *testing.T) {
+	dir := t.TempDir()
+	store := openFileStore(t, dir)
+
+	deck := toggleDecks.CreateFullDeck()
+	deck.Shuffle()
+	_ = store.Create("shuffled", deck)
+	deck.Draw(5)
+	_ = store.Update("shuffled", deck)
+
+	_ = store.Create("custom", toggleDecks.CreateDeck("AS KH 8C"))
+	_ = store.Close()
+
+	reopened := openFileStore(t, dir)
+	defer reopened.Close()
+
+	loaded, ok := reopened.Get("shuffled")
+	if !ok {
+		t.Fatal("Shuffled deck was not reloaded.")
+	}
+
+	if loaded.String() != deck.String() || !loaded.Shuffled || loaded.Len() != 47 {
+		t.Errorf("Shuffled deck did not come back the same.\n\tExpected: %v\n\tGot:      %v", deck, loaded)
+	}
+
+	if !loaded.Created.Equal(deck.Created) {
+		t.Errorf("Creation time was not kept. Expected %v, got %v", deck.Created, loaded.Created)
+	}
+
+	custom, ok := reopened.Get("custom")
+	if !ok || custom.String() != "AS KH 8C" || custom.Shuffled {
+		t.Errorf("Custom deck did not come back the same, got %v", custom)
+	}
+}
+
+// Deleting and clearing are remembered too.
+func TestFileStoreRemembersDeletesAndClears(t *testing.T) {
+	dir := t.TempDir()
+	store := openFileStore(t, dir)
+	for _, id := range []string{"one", "two", "three"} {
+		_ = store.Create(id, toggleDecks.CreateFullDeck())
+	}
+	_ = store.Delete("two")
+	_ = store.Close()
+
+	store = openFileStore(t, dir)
+	if len(store.List()) != 2 {
+		t.Errorf("Expected 2 decks after a delete, got %v", len(store.List()))
+	}
+	if _, ok := store.Get("two"); ok {
+		t.Error("Deleted deck came back.")
+	}
+
+	_ = store.Clear()
+	_ = store.Create("four", toggleDecks.CreateFullDeck())
+	_ = store.Close()
+
+	store = openFileStore(t, dir)
+	defer store.Close()
+	if ids := store.List(); len(ids) != 1 || ids[0] != "four" {
+		t.Errorf("Expected only the deck created after clearing, got %v", ids)
+	}
+}
+
+// Once the log has enough records in it, it is compacted into a snapshot, and nothing is lost doing so.
+func TestFileStoreCompactsTheLog(t *testing.T) {
+	dir := t.TempDir()
+	store := openFileStore(t, dir)
+	store.CompactEvery = 5
+
+	for i := 0; i < 12; i++ {
+		_ = store.Create(fmt.Sprint(i), toggleDecks.CreateFullDeck())
+	}
+	_ = store.Close()
+
+	if _, err := os.Stat(filepath.Join(dir, "decks.snapshot")); err != nil {
+		t.Errorf("No snapshot was written: %v", err)
+	}
+
+	logData, _ := os.ReadFile(filepath.Join(dir, "decks.log"))
+	if lines := countLines(logData); lines != 2 {
+		t.Errorf("Expected the log to hold only the 2 records since the last compaction, got %v", lines)
+	}
+
+	store = openFileStore(t, dir)
+	defer store.Close()
+	if len(store.List()) != 12 {
+		t.Errorf("Expected 12 decks after compaction, got %v", len(store.List()))
+	}
+}
+
+// A record cut off part way through (the process died while writing it) is dropped, and the store carries on.
+func TestFileStoreDiscardsATornRecord(t *testing.T) {
+	dir := t.TempDir()
+	store := openFileStore(t, dir)
+	_ = store.Create("kept", toggleDecks.CreateDeck("AS KH"))
+	// No Close - the process "dies" here, half way through writing the next record.
+
+	f, _ := os.OpenFile(filepath.Join(dir, "decks.log"), os.O_WRONLY|os.O_APPEND, 0644)
+	_, _ = f.WriteString(`{"op":"put","id":"lost","deck":{"cards":["AS","K`)
+	_ = f.Close()
+
+	store = openFileStore(t, dir)
+	if _, ok := store.Get("lost"); ok {
+		t.Error("Torn record was loaded.")
+	}
+	if _, ok := store.Get("kept"); !ok {
+		t.Fatal("Record written before the torn one was lost.")
+	}
+
+	// And what gets written afterwards isn't hidden behind the torn record.
+	_ = store.Create("after", toggleDecks.CreateDeck("QD"))
+	_ = store.Close()
+
+	store = openFileStore(t, dir)
+	defer store.Close()
+	if len(store.List()) != 2 {
+		t.Errorf("Expected the kept deck and the one written after the crash, got %v", store.List())
+	}
+}
+
+// A snapshot left half written by a crash during compaction is ignored in favor of the old snapshot and log.
+func TestFileStoreIgnoresAnUnfinishedSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	store := openFileStore(t, dir)
+	_ = store.Create("kept", toggleDecks.CreateDeck("AS KH"))
+	_ = store.Close()
+
+	_ = os.WriteFile(filepath.Join(dir, "decks.snapshot.tmp"), []byte(`{"decks":{"bogus":`), 0644)
+
+	store = openFileStore(t, dir)
+	defer store.Close()
+	if ids := store.List(); len(ids) != 1 || ids[0] != "kept" {
+		t.Errorf("Expected only the kept deck, got %v", ids)
+	}
+}
+
+// Not part of the suite on its own: run as a child process by TestFileStoreSurvivesBeingKilled, it writes to the
+// store as fast as it can until it is killed.
+func TestFileStoreWriterProcess(t *testing.T) {
+	dir := os.Getenv("TOGGLEDECKS_WRITER_DIR")
+	if dir == "" {
+		t.Skip("Only run as a child of TestFileStoreSurvivesBeingKilled.")
+	}
+
+	store := openFileStore(t, dir)
+	store.CompactEvery = 25
+	for i := 0; ; i++ {
+		deck := toggleDecks.CreateFullDeck()
+		deck.Shuffle()
+		_ = store.Create(fmt.Sprint(i), deck)
+		deck.Draw(i % 52)
+		_ = store.Update(fmt.Sprint(i), deck)
+	}
+}
+
+// Kill a process in the middle of writing to the store, and it still opens cleanly with intact decks afterwards.
+func TestFileStoreSurvivesBeingKilled(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping the kill test in short mode.")
+	}
+
+	dir := t.TempDir()
+	for round := 0; round < 3; round++ {
+		writer := exec.Command(os.Args[0], "-test.run=^TestFileStoreWriterProcess$")
+		writer.Env = append(os.Environ(), "TOGGLEDECKS_WRITER_DIR="+dir)
+		if err := writer.Start(); err != nil {
+			t.Fatalf("Unable to start the writer process: %v", err)
+		}
+
+		time.Sleep(time.Duration(200+100*round) * time.Millisecond)
+		_ = writer.Process.Kill()
+		_ = writer.Wait()
+
+		store := openFileStore(t, dir)
+		if len(store.List()) == 0 {
+			t.Errorf("Round %v: nothing was saved before the writer was killed.", round)
+		}
+
+		for _, id := range store.List() {
+			deck, _ := store.Get(id)
+			if deck.Len() > 52 {
+				t.Errorf("Round %v: deck %v came back damaged: %v", round, id, deck)
+			}
+			for _, c := range deck.Cards {
+				if c.Rank() == "" || c.Suite() == "" {
+					t.Errorf("Round %v: deck %v came back with a bad card %v", round, id, c)
+				}
+			}
+		}
+		_ = store.Close()
+	}
+}
+
+// Decks created through the REST api are there for a new app started on the same data directory.
+func TestFileStoreBackedAppSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	store := openFileStore(t, dir)
+	first := toggleDecks.NewApp(store)
+	iid := first.NewDeck("AS KH 8C", false)
+
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/api/v1/decks/%v/draw?count=1", iid), nil)
+	first.Router.ServeHTTP(httptest.NewRecorder(), req)
+	_ = store.Close()
+
+	store = openFileStore(t, dir)
+	defer store.Close()
+	second := toggleDecks.NewApp(store)
+
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/api/v1/decks/%v", iid), nil)
+	rr := httptest.NewRecorder()
+	second.Router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Recived wrong status code. Expected %v, got %v.", http.StatusOK, rr.Code)
+	}
+
+	expected := fmt.Sprintf(`{"deck_id":"%v","shuffled":false,"remaining":2,"version":1,"cards":[{"value":"KING","suite":"HEARTS","code":"KH"},{"value":"8","suite":"CLUBS","code":"8C"}]}`+"\n", iid)
+	if rr.Body.String() != expected {
+		t.Errorf("Wrong result returned.\n\tExpected : %v\n\tGot      : %v", expected, rr.Body.String())
+	}
+}
+
+// Count the newline terminated lines in some data.
+func countLines(data []byte) (lines int) {
+	for _, b := range data {
+		if b == '\n' {
+			lines++
+		}
+	}
+	return
+}
@@ -0,0 +1,113 @@
+package tests
+
+import (
+	"fmt"
+	"github.com/GamalielMasters/toggleDecks"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// A deck remembers what it started with and every draw made from it, in order, with when and how many.
+func TestDeckHistory(t *testing.T) {
+	clock := PatchClock()
+	defer UnPatchClock()
+
+	iid := app.NewDeck("AS KH 8C", false)
+	DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/draw?count=2", iid))
+	clock.Advance(time.Minute)
+	DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/draw?count=5", iid))
+
+	actual, status := DoRequest(t, "GET", fmt.Sprintf("/api/v1/decks/%v/history", iid))
+
+	if status != http.StatusOK {
+		t.Errorf("Recived wrong status code. Expected %v, got %v.", http.StatusOK, status)
+	}
+
+	expected := fmt.Sprintf(`{"deck_id":"%v",`+
+		`"original":[{"value":"ACE","suite":"SPADES","code":"AS"},{"value":"KING","suite":"HEARTS","code":"KH"},{"value":"8","suite":"CLUBS","code":"8C"}],`+
+		`"history":[`+
+		`{"time":"2020-01-01T12:00:00Z","action":"draw","count":2,"cards":[{"value":"ACE","suite":"SPADES","code":"AS"},{"value":"KING","suite":"HEARTS","code":"KH"}]},`+
+		`{"time":"2020-01-01T12:01:00Z","action":"draw","count":1,"cards":[{"value":"8","suite":"CLUBS","code":"8C"}]}]}`+"\n", iid)
+	if expected != actual {
+		t.Errorf("Wrong result returned.\n\tExpected : %v\n\tGot      : %v", expected, actual)
+	}
+}
+
+// Returns show up in the history too, including where the cards were put.
+func TestDeckHistoryIncludesReturns(t *testing.T) {
+	clock := PatchClock()
+	defer UnPatchClock()
+
+	iid := app.NewDeck("AS KH", false)
+	DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/draw", iid))
+	clock.Advance(time.Minute)
+	DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/return?cards=AS&position=bottom", iid))
+
+	actual, _ := DoRequest(t, "GET", fmt.Sprintf("/api/v1/decks/%v/history", iid))
+
+	expected := fmt.Sprintf(`{"deck_id":"%v",`+
+		`"original":[{"value":"ACE","suite":"SPADES","code":"AS"},{"value":"KING","suite":"HEARTS","code":"KH"}],`+
+		`"history":[`+
+		`{"time":"2020-01-01T12:00:00Z","action":"draw","count":1,"cards":[{"value":"ACE","suite":"SPADES","code":"AS"}]},`+
+		`{"time":"2020-01-01T12:01:00Z","action":"return","count":1,"cards":[{"value":"ACE","suite":"SPADES","code":"AS"}],"position":"bottom"}]}`+"\n", iid)
+	if expected != actual {
+		t.Errorf("Wrong result returned.\n\tExpected : %v\n\tGot      : %v", expected, actual)
+	}
+}
+
+// A brand new deck has no history to speak of.
+func TestNewDeckHasEmptyHistory(t *testing.T) {
+	iid := app.NewDeck("AS", false)
+
+	actual, _ := DoRequest(t, "GET", fmt.Sprintf("/api/v1/decks/%v/history", iid))
+
+	expected := fmt.Sprintf(`{"deck_id":"%v","original":[{"value":"ACE","suite":"SPADES","code":"AS"}],"history":[]}`+"\n", iid)
+	if expected != actual {
+		t.Errorf("Wrong result returned.\n\tExpected : %v\n\tGot      : %v", expected, actual)
+	}
+}
+
+// Opening a deck can list what has been dealt out of it, if you ask.
+func TestOpenDeckWithDrawnCards(t *testing.T) {
+	iid := app.NewDeck("AS KH 8C QD", false)
+	DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/draw?count=3", iid))
+	DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/return?cards=KH", iid))
+
+	actual, status := DoRequest(t, "GET", fmt.Sprintf("/api/v1/decks/%v?drawn=true", iid))
+
+	if status != http.StatusOK {
+		t.Errorf("Recived wrong status code. Expected %v, got %v.", http.StatusOK, status)
+	}
+
+	expected := fmt.Sprintf(`{"deck_id":"%v","shuffled":false,"remaining":2,"version":2,`+
+		`"cards":[{"value":"KING","suite":"HEARTS","code":"KH"},{"value":"QUEEN","suite":"DIAMONDS","code":"QD"}],`+
+		`"drawn":[{"value":"ACE","suite":"SPADES","code":"AS"},{"value":"8","suite":"CLUBS","code":"8C"}]}`+"\n", iid)
+	if expected != actual {
+		t.Errorf("Wrong result returned.\n\tExpected : %v\n\tGot      : %v", expected, actual)
+	}
+}
+
+// The history of a deck that doesn't exist is a 404.
+func TestHistoryOfAnInvalidDeck(t *testing.T) {
+	_, status := DoRequest(t, "GET", "/api/v1/decks/INVALID_ID/history")
+
+	if status != http.StatusNotFound {
+		t.Errorf("Recived wrong status code. Expected %v, got %v.", http.StatusNotFound, status)
+	}
+}
+
+// Events count the cards that were dealt, not how many were asked for.
+func TestHistoryCountsTheCardsDealt(t *testing.T) {
+	deck := toggleDecks.CreateDeck("AS KH")
+	_ = deck.CreatePile("hand")
+	deck.Draw(1)
+	_, _ = deck.DrawToPile("hand", 5)
+
+	_, history := deck.GetHistory()
+	for _, event := range history {
+		if event.Count != len(event.Cards) {
+			t.Errorf("The %v event counts %v cards, but has %v.", event.Action, event.Count, len(event.Cards))
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package tests
+
+import (
+	"fmt"
+	"github.com/GamalielMasters/toggleDecks"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Test idempotency keys on drawing from and creating decks.
+
+// A draw sent again with the same key gets the same cards, and doesn't draw any more.
+func TestDrawRetriedWithIdempotencyKey(t *testing.T) {
+	iid := app.NewDeck("AS KH 8C QD", false)
+	url := fmt.Sprintf("/api/v1/decks/%v/draw?count=2", iid)
+
+	first := DoHeaderRequest(t, "POST", url, "", map[string]string{toggleDecks.IdempotencyKeyHeader: "draw-1"})
+	if first.Code != http.StatusOK {
+		t.Fatalf("Recived wrong status code. Expected %v, got %v.", http.StatusOK, first.Code)
+	}
+
+	retry := DoHeaderRequest(t, "POST", url, "", map[string]string{toggleDecks.IdempotencyKeyHeader: "draw-1"})
+	if retry.Code != http.StatusOK || retry.Body.String() != first.Body.String() {
+		t.Errorf("The retry was not answered with the first response.\n\tExpected : %v\n\tGot      : %v", first.Body.String(), retry.Body.String())
+	}
+
+	if retry.Header().Get(toggleDecks.IdempotentReplayedHeader) != "true" || first.Header().Get(toggleDecks.IdempotentReplayedHeader) != "" {
+		t.Error("Only the retry should be marked as replayed.")
+	}
+
+	if etag := retry.Header().Get("ETag"); etag != `"1"` {
+		t.Errorf("The retry was sent the wrong ETag.  Expected \"1\", got %v", etag)
+	}
+
+	deck, _ := app.GetDeck(iid)
+	if deck.String() != "8C QD" {
+		t.Errorf("The retry drew more cards.  Expected 8C QD left, got %v", deck.String())
+	}
+
+	if next := DoHeaderRequest(t, "POST", url, "", map[string]string{toggleDecks.IdempotencyKeyHeader: "draw-2"}); next.Code != http.StatusOK || deck.Len() != 0 {
+		t.Errorf("A draw with a new key did not draw.  Got %v with %v cards left", next.Code, deck.Len())
+	}
+}
+
+// A key can't be used again for a different draw.
+func TestIdempotencyKeyReusedForDifferentDraw(t *testing.T) {
+	iid := app.NewDeck("AS KH 8C QD", false)
+	url := fmt.Sprintf("/api/v1/decks/%v/draw", iid)
+
+	DoHeaderRequest(t, "POST", url+"?count=1", "", map[string]string{toggleDecks.IdempotencyKeyHeader: "reused"})
+	CheckErrorResponse(t, DoHeaderRequest(t, "POST", url+"?count=2", "", map[string]string{toggleDecks.IdempotencyKeyHeader: "reused"}), http.StatusUnprocessableEntity, toggleDecks.ErrorIdempotencyKeyReused)
+
+	deck, _ := app.GetDeck(iid)
+	if deck.Len() != 3 {
+		t.Errorf("The refused draw drew cards.  Expected 3 left, got %v", deck.Len())
+	}
+}
+
+// Keys belong to the deck, so the same key can be used on another deck.
+func TestIdempotencyKeysArePerDeck(t *testing.T) {
+	first := app.NewDeck("AS KH", false)
+	second := app.NewDeck("AS KH", false)
+
+	DoHeaderRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/draw", first), "", map[string]string{toggleDecks.IdempotencyKeyHeader: "shared"})
+	rr := DoHeaderRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/draw", second), "", map[string]string{toggleDecks.IdempotencyKeyHeader: "shared"})
+
+	deck, _ := app.GetDeck(second)
+	if rr.Code != http.StatusOK || rr.Header().Get(toggleDecks.IdempotentReplayedHeader) != "" || deck.Len() != 1 {
+		t.Errorf("A key used on another deck was not a new draw.  Got %v with %v cards left", rr.Code, deck.Len())
+	}
+}
+
+// Failed draws aren't remembered, so the key can be used once the request is fixed.
+func TestFailedDrawsAreNotRemembered(t *testing.T) {
+	iid := app.NewDeck("AS KH", false)
+	url := fmt.Sprintf("/api/v1/decks/%v/draw", iid)
+
+	CheckErrorResponse(t, DoHeaderRequest(t, "POST", url+"?count=0", "", map[string]string{toggleDecks.IdempotencyKeyHeader: "fix-me"}), http.StatusBadRequest, toggleDecks.ErrorBadCount)
+
+	if rr := DoHeaderRequest(t, "POST", url+"?count=1", "", map[string]string{toggleDecks.IdempotencyKeyHeader: "fix-me"}); rr.Code != http.StatusOK {
+		t.Errorf("The fixed draw was refused.  Got %v %v", rr.Code, rr.Body.String())
+	}
+}
+
+// Keys are forgotten once the window has passed.
+func TestIdempotencyKeysExpire(t *testing.T) {
+	clock := PatchClock()
+	defer UnPatchClock()
+
+	iid := app.NewDeck("AS KH 8C", false)
+	url := fmt.Sprintf("/api/v1/decks/%v/draw", iid)
+
+	DoHeaderRequest(t, "POST", url, "", map[string]string{toggleDecks.IdempotencyKeyHeader: "old"})
+	clock.Advance(toggleDecks.DefaultIdempotencyWindow - time.Second)
+	DoHeaderRequest(t, "POST", url, "", map[string]string{toggleDecks.IdempotencyKeyHeader: "old"})
+
+	deck, _ := app.GetDeck(iid)
+	if deck.Len() != 2 {
+		t.Errorf("A retry inside the window drew again.  Expected 2 left, got %v", deck.Len())
+	}
+
+	clock.Advance(time.Second)
+	rr := DoHeaderRequest(t, "POST", url, "", map[string]string{toggleDecks.IdempotencyKeyHeader: "old"})
+	if rr.Header().Get(toggleDecks.IdempotentReplayedHeader) != "" || deck.Len() != 1 {
+		t.Errorf("A key outside the window was replayed.  Got %v cards left", deck.Len())
+	}
+	if len(deck.IdempotentResponses) != 1 {
+		t.Errorf("Expired keys were kept.  Expected 1, got %v", len(deck.IdempotentResponses))
+	}
+}
+
+// Malformed keys are refused.
+func TestBadIdempotencyKey(t *testing.T) {
+	iid := app.NewDeck("AS KH", false)
+
+	rr := DoHeaderRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/draw", iid), "", map[string]string{toggleDecks.IdempotencyKeyHeader: "has spaces"})
+	CheckErrorResponse(t, rr, http.StatusBadRequest, toggleDecks.ErrorBadIdempotencyKey)
+
+	rr = DoHeaderRequest(t, "POST", "/api/v1/decks", "", map[string]string{toggleDecks.IdempotencyKeyHeader: strings.Repeat("k", 256)})
+	CheckErrorResponse(t, rr, http.StatusBadRequest, toggleDecks.ErrorBadIdempotencyKey)
+}
+
+// Creating a deck again with the same key gets the same deck, rather than another one.
+func TestCreateRetriedWithIdempotencyKey(t *testing.T) {
+	app.ClearTheDatabase()
+	UnPatchUID()
+
+	body := `{"cards": ["AS", "KH"], "labels": {"table": "7"}}`
+	first := DoHeaderRequest(t, "POST", "/api/v1/decks", body, map[string]string{toggleDecks.IdempotencyKeyHeader: "create-1", "Content-Type": "application/json"})
+	retry := DoHeaderRequest(t, "POST", "/api/v1/decks", body, map[string]string{toggleDecks.IdempotencyKeyHeader: "create-1", "Content-Type": "application/json; charset=utf-8"})
+
+	if first.Code != http.StatusOK || retry.Body.String() != first.Body.String() || retry.Header().Get(toggleDecks.IdempotentReplayedHeader) != "true" {
+		t.Errorf("The retry was not answered with the first response.\n\tExpected : %v\n\tGot      : %v", first.Body.String(), retry.Body.String())
+	}
+
+	if len(app.Store.List()) != 1 {
+		t.Errorf("The retry created another deck.  Expected 1 deck, got %v", len(app.Store.List()))
+	}
+
+	rr := DoHeaderRequest(t, "POST", "/api/v1/decks", `{"cards": ["AS"]}`, map[string]string{toggleDecks.IdempotencyKeyHeader: "create-1", "Content-Type": "application/json"})
+	CheckErrorResponse(t, rr, http.StatusUnprocessableEntity, toggleDecks.ErrorIdempotencyKeyReused)
+
+	rr = DoHeaderRequest(t, "POST", "/api/v1/decks?cards=AS,KH", "", map[string]string{toggleDecks.IdempotencyKeyHeader: "create-1"})
+	CheckErrorResponse(t, rr, http.StatusUnprocessableEntity, toggleDecks.ErrorIdempotencyKeyReused)
+}
+
+// Of many creations sent at once with the same key, only one deck is created, and every one gets it.
+func TestConcurrentCreatesWithIdempotencyKey(t *testing.T) {
+	app.ClearTheDatabase()
+	UnPatchUID()
+
+	const clients = 20
+	bodies := make(chan string, clients)
+	var wg sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bodies <- DoHeaderRequest(t, "POST", "/api/v1/decks?shuffle=true", "", map[string]string{toggleDecks.IdempotencyKeyHeader: "create-at-once"}).Body.String()
+		}()
+	}
+	wg.Wait()
+	close(bodies)
+
+	first := <-bodies
+	for body := range bodies {
+		if body != first {
+			t.Errorf("Clients got different decks.\n\t%v\n\t%v", first, body)
+		}
+	}
+
+	if len(app.Store.List()) != 1 {
+		t.Errorf("More than one deck was created.  Expected 1, got %v", len(app.Store.List()))
+	}
+}
+
+// A draw is stored along with its key, in one go, so if that fails the draw is undone and the request fails.  A retry
+// then draws afresh, rather than replaying a response that was never stored.
+func TestDrawWithIdempotencyKeyStoredAtOnce(t *testing.T) {
+	store := &countingStore{DeckStore: &failingStore{DeckStore: toggleDecks.NewMemoryDeckStore()}}
+	custom := toggleDecks.NewApp(store)
+	iid, deck, _ := custom.StoreNewDeck(toggleDecks.DeckOptions{Cards: "AS KH 8C"})
+	url := fmt.Sprintf("/api/v1/decks/%v/draw", iid)
+
+	send := func() *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", url, nil)
+		req.Header.Set(toggleDecks.IdempotencyKeyHeader, "once")
+		rr := httptest.NewRecorder()
+		custom.Router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	store.DeckStore.(*failingStore).failing = true
+	if rr := send(); rr.Code != http.StatusInternalServerError || rr.Header().Get("ETag") != "" {
+		t.Errorf("A draw that couldn't be stored did not fail.  Got %v %v", rr.Code, rr.Body.String())
+	}
+	if deck.Len() != 3 || len(deck.IdempotentResponses) != 0 {
+		t.Errorf("The failed draw was kept: %v cards left, %v keys", deck.Len(), len(deck.IdempotentResponses))
+	}
+
+	store.DeckStore.(*failingStore).failing = false
+	store.updates = 0
+	if rr := send(); rr.Code != http.StatusOK || rr.Header().Get(toggleDecks.IdempotentReplayedHeader) != "" {
+		t.Errorf("The retry did not draw.  Got %v %v", rr.Code, rr.Body.String())
+	}
+	if store.updates != 1 || deck.Len() != 2 || len(deck.IdempotentResponses) != 1 {
+		t.Errorf("The draw and its key were not stored at once: %v updates, %v cards left, %v keys", store.updates, deck.Len(), len(deck.IdempotentResponses))
+	}
+}
+
+// Only so many keys for creating decks are kept, and the oldest are forgotten first.
+func TestCreationIdempotencyKeysAreCapped(t *testing.T) {
+	app.ClearTheDatabase()
+	UnPatchUID()
+
+	first := DoHeaderRequest(t, "POST", "/api/v1/decks", "", map[string]string{toggleDecks.IdempotencyKeyHeader: "key-0"})
+	for i := 1; i <= toggleDecks.MaxCreationIdempotencyKeys; i++ {
+		DoHeaderRequest(t, "POST", "/api/v1/decks", "", map[string]string{toggleDecks.IdempotencyKeyHeader: fmt.Sprintf("key-%v", i)})
+	}
+
+	last := fmt.Sprintf("key-%v", toggleDecks.MaxCreationIdempotencyKeys)
+	if rr := DoHeaderRequest(t, "POST", "/api/v1/decks", "", map[string]string{toggleDecks.IdempotencyKeyHeader: last}); rr.Header().Get(toggleDecks.IdempotentReplayedHeader) != "true" {
+		t.Error("The newest key was forgotten.")
+	}
+
+	if rr := DoHeaderRequest(t, "POST", "/api/v1/decks", "", map[string]string{toggleDecks.IdempotencyKeyHeader: "key-0"}); rr.Header().Get(toggleDecks.IdempotentReplayedHeader) != "" || rr.Body.String() == first.Body.String() {
+		t.Error("The oldest key was kept beyond the limit.")
+	}
+}
@@ -0,0 +1,72 @@
+package tests
+
+import (
+	"fmt"
+	"github.com/GamalielMasters/toggleDecks"
+	"net/http"
+	"testing"
+)
+
+// Test decks with jokers in them.
+
+// Asking for jokers adds the red and black jokers to the bottom of a standard deck.
+func TestCreateDeckWithJokers(t *testing.T) {
+	actual, status := DoCreateRequest(t, "POST", "/api/v1/decks?jokers_enabled=true")
+
+	if status != http.StatusOK {
+		t.Errorf("Recived wrong status code. Expected %v, got %v.", http.StatusOK, status)
+	}
+
+	expected := `{"deck_id":"a251071b-662f-44b6-ba11-e24863039c59","shuffled":false,"remaining":54,"version":0}` + "\n"
+	if expected != actual {
+		t.Errorf("Wrong result returned.\n\tExpected : %v\n\tGot      : %v", expected, actual)
+	}
+
+	deck, _ := app.GetDeck("a251071b-662f-44b6-ba11-e24863039c59")
+	if deck.String() != toggleDecks.STANDARD_DECK+" RJ BJ" {
+		t.Errorf("Jokers were not added to the bottom of the deck: %v", deck)
+	}
+}
+
+// Jokers are rendered without a suite.
+func TestDrawJokers(t *testing.T) {
+	iid := app.NewDeck("RJ BJ AS", false)
+
+	actual, _ := DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/draw?count=3", iid))
+
+	expected := `{"cards":[{"value":"RED JOKER","code":"RJ"},{"value":"BLACK JOKER","code":"BJ"},{"value":"ACE","suite":"SPADES","code":"AS"}]}` + "\n"
+	if expected != actual {
+		t.Errorf("Wrong result returned.\n\tExpected : %v\n\tGot      : %v", expected, actual)
+	}
+}
+
+// Jokers can be listed in a custom deck, and returned like any other card.
+func TestCustomDeckWithJokers(t *testing.T) {
+	_, status := DoCreateRequest(t, "POST", "/api/v1/decks?cards=AS,RJ,KH")
+	if status != http.StatusOK {
+		t.Fatalf("Recived wrong status code. Expected %v, got %v.", http.StatusOK, status)
+	}
+
+	iid := "a251071b-662f-44b6-ba11-e24863039c59"
+	DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/draw?count=2", iid))
+	_, status = DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/return?cards=RJ", iid))
+	if status != http.StatusOK {
+		t.Errorf("Recived wrong status code returning a joker. Expected %v, got %v.", http.StatusOK, status)
+	}
+
+	// Only the two jokers there are, though.
+	_, status = DoRequest(t, "POST", "/api/v1/decks?cards=AS,GJ")
+	if status != http.StatusBadRequest {
+		t.Errorf("Recived wrong status code for a made up joker. Expected %v, got %v.", http.StatusBadRequest, status)
+	}
+}
+
+// Every deck in a shoe brings its own jokers.
+func TestShoeWithJokers(t *testing.T) {
+	DoCreateRequest(t, "POST", "/api/v1/decks?jokers_enabled=true&deck_count=2&cards=AS")
+
+	deck, _ := app.GetDeck("a251071b-662f-44b6-ba11-e24863039c59")
+	if s := deck.String(); s != "AS RJ BJ AS RJ BJ" {
+		t.Errorf("Wrong shoe of jokers: %v", s)
+	}
+}
@@ -0,0 +1,113 @@
+package tests
+
+import (
+	"fmt"
+	"github.com/GamalielMasters/toggleDecks"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// Test labelling decks, and keeping metadata with them.
+
+// Labels can be given in the query string when a deck is created, and come back with its details.
+func TestCreateDeckWithLabels(t *testing.T) {
+	actual, status := DoCreateRequest(t, "POST", "/api/v1/decks?label=table:7&label=game:hold-em")
+
+	if status != http.StatusOK {
+		t.Fatalf("Recived wrong status code. Expected %v, got %v: %v", http.StatusOK, status, actual)
+	}
+
+	message := decodeDeckMessage(t, actual)
+	if len(message.Labels) != 2 || message.Labels["table"] != "7" || message.Labels["game"] != "hold-em" {
+		t.Errorf("The deck was not labelled: %v", message.Labels)
+	}
+}
+
+// Labels and metadata beyond the limits are refused.
+func TestCreateDeckWithBadLabels(t *testing.T) {
+	cases := []string{
+		`{"labels": {"bad name": "x"}}`,
+		`{"labels": {"long": "` + strings.Repeat("x", toggleDecks.MaxLabelValueLength+1) + `"}}`,
+		`{"metadata": {"notes": "` + strings.Repeat("x", toggleDecks.MaxMetadataSize) + `"}}`,
+	}
+
+	for _, body := range cases {
+		actual, status := DoCreateJSONRequest(t, body)
+		if status != http.StatusBadRequest || !strings.Contains(actual, `"code":"bad_labels"`) {
+			t.Errorf("Bad labels were not refused.  Got %v %v", status, actual)
+		}
+	}
+
+	if actual, status := DoCreateRequest(t, "POST", "/api/v1/decks?label=novalue"); status != http.StatusBadRequest || !strings.Contains(actual, `"code":"bad_labels"`) {
+		t.Errorf("A label without a value was not refused.  Got %v %v", status, actual)
+	}
+}
+
+// Patching a deck sets the labels and metadata given, removes those set to null, and leaves the rest alone.
+func TestPatchDeckLabelsAndMetadata(t *testing.T) {
+	DoCreateJSONRequest(t, `{"labels": {"table": "7", "game": "hold-em"}, "metadata": {"dealer": "jo", "round": 1}}`)
+	iid := GuidMock{}.GenerateIdentifier()
+
+	actual, status := DoBodyRequest(t, "PATCH", "/api/v1/decks/"+iid, `{"labels": {"table": "8", "game": null, "player": "p1"}, "metadata": {"round": 2, "dealer": null}}`)
+	if status != http.StatusOK {
+		t.Fatalf("Recived wrong status code. Expected %v, got %v: %v", http.StatusOK, status, actual)
+	}
+
+	message := decodeDeckMessage(t, actual)
+	if len(message.Labels) != 2 || message.Labels["table"] != "8" || message.Labels["player"] != "p1" {
+		t.Errorf("The labels were not patched: %v", message.Labels)
+	}
+	if len(message.Metadata) != 1 || message.Metadata["round"] != 2.0 {
+		t.Errorf("The metadata was not patched: %v", message.Metadata)
+	}
+
+	opened, _ := DoRequest(t, "GET", "/api/v1/decks/"+iid)
+	if !strings.Contains(opened, `"labels":{"player":"p1","table":"8"}`) {
+		t.Errorf("The patched labels are not in the deck's details: %v", opened)
+	}
+}
+
+// A patch that would go beyond the limits changes nothing.
+func TestBadPatchChangesNothing(t *testing.T) {
+	DoCreateJSONRequest(t, `{"labels": {"table": "7"}}`)
+	iid := GuidMock{}.GenerateIdentifier()
+
+	labels := make([]string, toggleDecks.MaxLabels)
+	for i := range labels {
+		labels[i] = fmt.Sprintf(`"l%v": "x"`, i)
+	}
+
+	for _, body := range []string{`{"labels": {` + strings.Join(labels, ", ") + `}}`, `{"labels": {"table": 7}}`, `{"tags": []}`} {
+		actual, status := DoBodyRequest(t, "PATCH", "/api/v1/decks/"+iid, body)
+		if status != http.StatusBadRequest {
+			t.Errorf("A bad patch was not refused.  Got %v %v", status, actual)
+		}
+	}
+
+	deck, _ := app.GetDeck(iid)
+	if len(deck.Labels) != 1 || deck.Labels["table"] != "7" {
+		t.Errorf("A refused patch changed the labels: %v", deck.Labels)
+	}
+}
+
+// Decks can be listed by their labels.
+func TestListDecksByLabel(t *testing.T) {
+	app.ClearTheDatabase()
+	seven := app.NewDeck("", false)
+	eight := app.NewDeck("", false)
+	DoBodyRequest(t, "PATCH", "/api/v1/decks/"+seven, `{"labels": {"table": "7", "game": "poker"}}`)
+	DoBodyRequest(t, "PATCH", "/api/v1/decks/"+eight, `{"labels": {"table": "8", "game": "poker"}}`)
+
+	if ids := listedIds(listDecks(t, "label=table:7")); len(ids) != 1 || ids[0] != seven {
+		t.Errorf("The deck at table 7 was not listed alone: %v", ids)
+	}
+
+	if ids := listedIds(listDecks(t, "label=game:poker")); len(ids) != 2 {
+		t.Errorf("Both poker decks were not listed: %v", ids)
+	}
+
+	if ids := listedIds(listDecks(t, "label=game:poker&label=table:9")); len(ids) != 0 {
+		t.Errorf("Decks were listed for a table with none: %v", ids)
+	}
+}
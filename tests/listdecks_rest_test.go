@@ -1,9 +1,15 @@
 package tests
 
 import (
+	"encoding/json"
 	"fmt"
+	"github.com/GamalielMasters/toggleDecks"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 )
 
 // When you visit the endpoint for decks with a get request, you get a list of all the decks in the system.
@@ -37,3 +43,155 @@ func TestListNoDeck(t *testing.T) {
 		t.Errorf("Wrong result returned.\n\tExpected : %v\n\tGot      : %v", expected, actual)
 	}
 }
+
+// Create decks a minute apart, so they are listed in the order created.  Each is described by a JSON create request.
+func createListedDecks(t *testing.T, clock *ClockMock, requests ...string) (ids []string) {
+	app.ClearTheDatabase()
+	for _, request := range requests {
+		req, _ := http.NewRequest("POST", "/api/v1/decks", strings.NewReader(request))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		app.Router.ServeHTTP(rr, req)
+		message := decodeDeckMessage(t, rr.Body.String())
+		ids = append(ids, message.Id)
+		clock.Advance(time.Minute)
+	}
+	return
+}
+
+// List decks with the passed query, and decode the page.
+func listDecks(t *testing.T, query string) (message toggleDecks.ListDeckMessage) {
+	actual, status := DoRequest(t, "GET", "/api/v1/decks?"+query)
+	if status != http.StatusOK {
+		t.Fatalf("Listing decks with %v failed: %v %v", query, status, actual)
+	}
+	if err := json.Unmarshal([]byte(actual), &message); err != nil {
+		t.Fatalf("Unable to decode the deck list %v: %v", actual, err)
+	}
+	return
+}
+
+// The IDs of the listed decks.
+func listedIds(message toggleDecks.ListDeckMessage) (ids []string) {
+	for _, deck := range message.Decks {
+		ids = append(ids, deck.Id)
+	}
+	return
+}
+
+// Decks are listed oldest first, a page at a time, and every deck is listed once however the pages are split.
+func TestListDecksAPageAtATime(t *testing.T) {
+	clock := PatchClock()
+	defer UnPatchClock()
+	ids := createListedDecks(t, clock, "{}", "{}", "{}", "{}", "{}")
+
+	var listed []string
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > len(ids) {
+			t.Fatal("Paging through the decks never ended.")
+		}
+
+		page := listDecks(t, "limit=2&cursor="+url.QueryEscape(cursor))
+		if len(page.Decks) > 2 {
+			t.Errorf("A page had %v decks, more than the limit.", len(page.Decks))
+		}
+		listed = append(listed, listedIds(page)...)
+
+		if cursor = page.NextCursor; len(cursor) == 0 {
+			break
+		}
+	}
+
+	if strings.Join(listed, " ") != strings.Join(ids, " ") {
+		t.Errorf("Decks were not listed in the order created.\n\tExpected : %v\n\tGot      : %v", ids, listed)
+	}
+}
+
+// Decks can be picked out by whether they are shuffled or empty, when they were created, and their owner and tags.
+func TestListDecksWithFilters(t *testing.T) {
+	clock := PatchClock()
+	defer UnPatchClock()
+	start := toggleDecks.TheClock.Now()
+	ids := createListedDecks(t, clock,
+		`{"shuffle": true, "metadata": {"owner": "jo", "tags": ["poker", "high-stakes"]}}`,
+		`{"cards": ["AS"], "metadata": {"owner": "sam", "tags": ["poker"]}}`,
+		`{"metadata": {"owner": "jo"}}`,
+	)
+	DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/draw", ids[1]))
+
+	cases := map[string][]string{
+		"shuffled=true":             {ids[0]},
+		"shuffled=false":            {ids[1], ids[2]},
+		"empty=true":                {ids[1]},
+		"owner=jo":                  {ids[0], ids[2]},
+		"tag=poker":                 {ids[0], ids[1]},
+		"tag=poker&tag=high-stakes": {ids[0]},
+		"owner=jo&shuffled=false":   {ids[2]},
+		"created_after=" + url.QueryEscape(start.Add(30*time.Second).Format(time.RFC3339)): {ids[1], ids[2]},
+	}
+
+	for query, expected := range cases {
+		if actual := listedIds(listDecks(t, query)); strings.Join(actual, " ") != strings.Join(expected, " ") {
+			t.Errorf("Wrong decks listed for %v.\n\tExpected : %v\n\tGot      : %v", query, expected, actual)
+		}
+	}
+}
+
+// Asked for, the list gives each deck's details as well as its ID.
+func TestListDecksWithSummaries(t *testing.T) {
+	clock := PatchClock()
+	defer UnPatchClock()
+	createListedDecks(t, clock, `{"cards": ["AS", "KH"]}`)
+
+	page := listDecks(t, "summary=true")
+	if len(page.Decks) != 1 {
+		t.Fatalf("Wrong number of decks listed: %v", len(page.Decks))
+	}
+
+	deck := page.Decks[0]
+	if deck.Remaining == nil || *deck.Remaining != 2 || deck.Shuffled == nil || *deck.Shuffled || deck.Created == nil || len(deck.Cards) != 0 {
+		t.Errorf("The deck was not summarized: %+v", deck)
+	}
+}
+
+// Bad filters, limits and cursors are refused.
+func TestListDecksWithBadParameters(t *testing.T) {
+	app.ClearTheDatabase()
+	cases := map[string]string{
+		"shuffled=maybe":      "bad_filter",
+		"created_after=today": "bad_filter",
+		"limit=0":             "bad_count",
+		"limit=1001":          "bad_count",
+		"cursor=!!!":          "bad_cursor",
+		"cursor=bm9wZQ":       "bad_cursor",
+	}
+
+	for query, code := range cases {
+		actual, status := DoRequest(t, "GET", "/api/v1/decks?"+query)
+		if status != http.StatusBadRequest || !strings.Contains(actual, `"code":"`+code+`"`) {
+			t.Errorf("%v was not refused with %v.  Got %v %v", query, code, status, actual)
+		}
+	}
+}
+
+// Decks that have expired aren't listed, even before the reaper removes them, just as they can't be opened.
+func TestListDecksLeavesOutExpiredDecks(t *testing.T) {
+	clock := PatchClock()
+	defer UnPatchClock()
+	ids := createListedDecks(t, clock, "{}", "{}", "{}")
+	app.IdleTTL = time.Hour
+	defer func() { app.IdleTTL = 0 }()
+
+	clock.Advance(30 * time.Minute)
+	DoRequest(t, "GET", "/api/v1/decks/"+ids[1])
+	clock.Advance(45 * time.Minute)
+
+	if listed := listedIds(listDecks(t, "")); fmt.Sprint(listed) != fmt.Sprint(ids[1:2]) {
+		t.Errorf("Wrong decks listed.  Expected %v, got %v", ids[1:2], listed)
+	}
+
+	if _, status := DoRequest(t, "GET", "/api/v1/decks/"+ids[0]); status != http.StatusNotFound {
+		t.Errorf("An expired deck could be opened.  Expected %v, got %v", http.StatusNotFound, status)
+	}
+}
@@ -16,7 +16,7 @@ func TestOpenADeck(t *testing.T) {
 		t.Errorf("Recived wrong status code. Expected %v, got %v.", http.StatusOK, status)
 	}
 
-	expected := fmt.Sprintf(`{"deck_id":"%v","shuffled":false,"remaining":3,"cards":[{"value":"ACE","suite":"SPADES","code":"AS"},{"value":"KING","suite":"HEARTS","code":"KH"},{"value":"8","suite":"CLUBS","code":"8C"}]}`+"\n", iid)
+	expected := fmt.Sprintf(`{"deck_id":"%v","shuffled":false,"remaining":3,"version":0,"cards":[{"value":"ACE","suite":"SPADES","code":"AS"},{"value":"KING","suite":"HEARTS","code":"KH"},{"value":"8","suite":"CLUBS","code":"8C"}]}`+"\n", iid)
 	if expected != actual {
 		t.Errorf("Wrong result returned.\n\tExpected : %v\n\tGot      : %v", expected, actual)
 	}
@@ -63,3 +63,19 @@ func TestOpenDeckWithMalformedId(t *testing.T) {
 		t.Errorf("Recived wrong status code. Expected %v, got %v.", http.StatusNotFound, status)
 	}
 }
+
+// Asked for, the cards come with their Unicode glyphs.
+func TestOpenADeckWithGlyphs(t *testing.T) {
+	iid := app.NewDeck("AS KH 8C", false)
+
+	actual, status := DoRequest(t, "GET", fmt.Sprintf("/api/v1/decks/%v?glyphs=true", iid))
+
+	if status != http.StatusOK {
+		t.Errorf("Recived wrong status code. Expected %v, got %v.", http.StatusOK, status)
+	}
+
+	expected := fmt.Sprintf(`{"deck_id":"%v","shuffled":false,"remaining":3,"version":0,"cards":[{"value":"ACE","suite":"SPADES","code":"AS","glyph":"🂡"},{"value":"KING","suite":"HEARTS","code":"KH","glyph":"🂾"},{"value":"8","suite":"CLUBS","code":"8C","glyph":"🃘"}]}`+"\n", iid)
+	if expected != actual {
+		t.Errorf("Wrong result returned.\n\tExpected : %v\n\tGot      : %v", expected, actual)
+	}
+}
@@ -0,0 +1,229 @@
+package tests
+
+import (
+	"fmt"
+	"github.com/GamalielMasters/toggleDecks"
+	"math/rand"
+	"net/http"
+	"testing"
+)
+
+// A deck can have piles: hands, discard piles, whatever the game calls for.  A new pile is empty.
+func TestCreateAPile(t *testing.T) {
+	iid := app.NewDeck("AS KH 8C", false)
+
+	actual, status := DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/piles/discard", iid))
+
+	if status != http.StatusOK {
+		t.Errorf("Recived wrong status code. Expected %v, got %v.", http.StatusOK, status)
+	}
+
+	expected := fmt.Sprintf(`{"deck_id":"%v","pile":"discard","remaining":0,"cards":[]}`+"\n", iid)
+	if expected != actual {
+		t.Errorf("Wrong result returned.\n\tExpected : %v\n\tGot      : %v", expected, actual)
+	}
+
+	// But you only get one pile by any name.
+	_, status = DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/piles/discard", iid))
+	if status != http.StatusConflict {
+		t.Errorf("Recived wrong status code. Expected %v, got %v.", http.StatusConflict, status)
+	}
+}
+
+// Cards are dealt from the deck onto a pile, and the pile lists them.
+func TestDrawIntoAPile(t *testing.T) {
+	iid := app.NewDeck("AS KH 8C", false)
+	DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/piles/alice", iid))
+
+	actual, status := DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/piles/alice/draw?count=2", iid))
+
+	if status != http.StatusOK {
+		t.Errorf("Recived wrong status code. Expected %v, got %v.", http.StatusOK, status)
+	}
+
+	expected := `{"cards":[{"value":"ACE","suite":"SPADES","code":"AS"},{"value":"KING","suite":"HEARTS","code":"KH"}]}` + "\n"
+	if expected != actual {
+		t.Errorf("Wrong result returned.\n\tExpected : %v\n\tGot      : %v", expected, actual)
+	}
+
+	actual, _ = DoRequest(t, "GET", fmt.Sprintf("/api/v1/decks/%v/piles/alice", iid))
+	expected = fmt.Sprintf(`{"deck_id":"%v","pile":"alice","remaining":2,"cards":[{"value":"ACE","suite":"SPADES","code":"AS"},{"value":"KING","suite":"HEARTS","code":"KH"}]}`+"\n", iid)
+	if expected != actual {
+		t.Errorf("Wrong pile returned.\n\tExpected : %v\n\tGot      : %v", expected, actual)
+	}
+
+	deck, _ := app.GetDeck(iid)
+	if deck.String() != "8C" {
+		t.Errorf("Dealt cards are still in the deck: %v", deck)
+	}
+}
+
+// Cards move from the top of one pile to another, or you can pick exactly which ones.
+func TestMoveCardsBetweenPiles(t *testing.T) {
+	iid := app.NewDeck("AS KH 8C QD", false)
+	DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/piles/hand", iid))
+	DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/piles/discard", iid))
+	DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/piles/hand/draw?count=4", iid))
+
+	actual, status := DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/piles/hand/move?to=discard&cards=8C", iid))
+	if status != http.StatusOK {
+		t.Errorf("Recived wrong status code. Expected %v, got %v.", http.StatusOK, status)
+	}
+
+	expected := `{"cards":[{"value":"8","suite":"CLUBS","code":"8C"}]}` + "\n"
+	if expected != actual {
+		t.Errorf("Wrong result returned.\n\tExpected : %v\n\tGot      : %v", expected, actual)
+	}
+
+	DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/piles/hand/move?to=discard&count=2", iid))
+
+	deck, _ := app.GetDeck(iid)
+	hand, _ := deck.GetPile("hand")
+	discard, _ := deck.GetPile("discard")
+	if fmt.Sprint(hand) != "[QD]" || fmt.Sprint(discard) != "[AS KH 8C]" {
+		t.Errorf("Cards did not end up in the right piles.  Hand %v, discard %v", hand, discard)
+	}
+
+	// Cards that aren't in the pile can't be moved out of it.
+	_, status = DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/piles/hand/move?to=discard&cards=AS", iid))
+	if status != http.StatusBadRequest {
+		t.Errorf("Recived wrong status code. Expected %v, got %v.", http.StatusBadRequest, status)
+	}
+}
+
+// Piles can be listed, and shuffled.
+func TestListAndShufflePiles(t *testing.T) {
+	iid := app.NewDeck("", false)
+	DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/piles/tableau", iid))
+	DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/piles/discard", iid))
+	DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/piles/tableau/draw?count=40", iid))
+
+	actual, status := DoRequest(t, "GET", fmt.Sprintf("/api/v1/decks/%v/piles", iid))
+	if status != http.StatusOK {
+		t.Errorf("Recived wrong status code. Expected %v, got %v.", http.StatusOK, status)
+	}
+
+	expected := fmt.Sprintf(`{"deck_id":"%v","piles":[{"pile":"discard","remaining":0},{"pile":"tableau","remaining":40}]}`+"\n", iid)
+	if expected != actual {
+		t.Errorf("Wrong result returned.\n\tExpected : %v\n\tGot      : %v", expected, actual)
+	}
+
+	deck, _ := app.GetDeck(iid)
+	before, _ := deck.GetPile("tableau")
+
+	_, status = DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/piles/tableau/shuffle", iid))
+	if status != http.StatusOK {
+		t.Errorf("Recived wrong status code. Expected %v, got %v.", http.StatusOK, status)
+	}
+
+	after, _ := deck.GetPile("tableau")
+	if fmt.Sprint(before) == fmt.Sprint(after) {
+		t.Error("Pile was not shuffled.")
+	}
+}
+
+// Piles that don't exist are not found, whichever end of a move they're on.
+func TestMissingPiles(t *testing.T) {
+	iid := app.NewDeck("", false)
+	DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/piles/hand", iid))
+
+	for _, request := range []struct{ method, url string }{
+		{"GET", fmt.Sprintf("/api/v1/decks/%v/piles/nope", iid)},
+		{"POST", fmt.Sprintf("/api/v1/decks/%v/piles/nope/draw", iid)},
+		{"POST", fmt.Sprintf("/api/v1/decks/%v/piles/nope/shuffle", iid)},
+		{"POST", fmt.Sprintf("/api/v1/decks/%v/piles/nope/move?to=hand", iid)},
+		{"POST", fmt.Sprintf("/api/v1/decks/%v/piles/hand/move?to=nope", iid)},
+	} {
+		_, status := DoRequest(t, request.method, request.url)
+		if status != http.StatusNotFound {
+			t.Errorf("%v %v: expected %v, got %v.", request.method, request.url, http.StatusNotFound, status)
+		}
+	}
+}
+
+// However the cards are moved around, none are ever created or lost.
+func TestCardsAreConservedAcrossPiles(t *testing.T) {
+	deck := toggleDecks.CreateFullDeck()
+	deck.Shuffle()
+	names := []string{"north", "south", "discard"}
+	for _, name := range names {
+		_ = deck.CreatePile(name)
+	}
+
+	r := rand.New(rand.NewSource(42))
+	for i := 0; i < 500; i++ {
+		from, to := names[r.Intn(3)], names[r.Intn(3)]
+		switch r.Intn(5) {
+		case 0:
+			_, _ = deck.DrawToPile(from, r.Intn(4))
+		case 1:
+			_, _ = deck.MovePileCards(from, to, r.Intn(4), nil)
+		case 2:
+			_ = deck.ShufflePile(from)
+		case 3:
+			deck.Draw(r.Intn(2))
+		case 4:
+			if drawn := deck.Drawn(); len(drawn) > 0 {
+				_ = deck.Return(drawn[:1], toggleDecks.ReturnRandom)
+			}
+		}
+
+		all := append(deck.Drawn(), deck.Cards...)
+		for _, name := range names {
+			pile, _ := deck.GetPile(name)
+			all = append(all, pile...)
+		}
+
+		if equal, expected, got := DeckContainsCards(toggleDecks.CreateDeck(cardsString(all)), toggleDecks.STANDARD_DECK); !equal {
+			t.Fatalf("After %v operations the cards don't add up.\n\tExpected: %v\n\tGot:      %v", i+1, expected, got)
+		}
+	}
+}
+
+// Join cards into a deck definition string.
+func cardsString(cards []toggleDecks.Card) string {
+	s := ""
+	for i, c := range cards {
+		if i > 0 {
+			s += " "
+		}
+		s += c.Code()
+	}
+	return s
+}
+
+// Moving a negative number of cards moves none, as with drawing.
+func TestMoveNegativeCountBetweenPiles(t *testing.T) {
+	deck := toggleDecks.CreateDeck("AS KH 8C")
+	_ = deck.CreatePile("hand")
+	_ = deck.CreatePile("discard")
+	_, _ = deck.DrawToPile("hand", 3)
+
+	moved, err := deck.MovePileCards("hand", "discard", -1, nil)
+	if err != nil || len(moved) != 0 {
+		t.Errorf("Moving -1 cards should move none.  Moved %v, error %v", moved, err)
+	}
+
+	hand, _ := deck.GetPile("hand")
+	if fmt.Sprint(hand) != "[AS KH 8C]" {
+		t.Errorf("The hand changed.  Expected [AS KH 8C], got %v", hand)
+	}
+}
+
+// Counts of cards to move follow the same rules as counts to draw.
+func TestMoveBadCountsBetweenPiles(t *testing.T) {
+	iid := app.NewDeck("AS KH 8C", false)
+	DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/piles/hand", iid))
+	DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/piles/discard", iid))
+	DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/piles/hand/draw?count=3", iid))
+
+	for _, count := range []string{"-1", "0", "abc", "1.5"} {
+		rr := DoHeaderRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/piles/hand/move?to=discard&count=%v", iid, count), "", nil)
+		CheckErrorResponse(t, rr, http.StatusBadRequest, toggleDecks.ErrorBadCount)
+	}
+
+	deck, _ := app.GetDeck(iid)
+	if hand, _ := deck.GetPile("hand"); len(hand) != 3 {
+		t.Errorf("Refused moves moved cards.  Expected 3 in the hand, got %v", hand)
+	}
+}
@@ -0,0 +1,85 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// Drawn cards can be handed back, and by default they go back on top.
+func TestReturnCardsToADeck(t *testing.T) {
+	iid := app.NewDeck("AS KH 8C", false)
+	DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/draw?count=2", iid))
+
+	actual, status := DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/return?cards=KH", iid))
+
+	if status != http.StatusOK {
+		t.Errorf("Recived wrong status code. Expected %v, got %v.", http.StatusOK, status)
+	}
+
+	expected := fmt.Sprintf(`{"deck_id":"%v","shuffled":false,"remaining":2,"version":2}`+"\n", iid)
+	if expected != actual {
+		t.Errorf("Wrong result returned.\n\tExpected : %v\n\tGot      : %v", expected, actual)
+	}
+
+	actual, _ = DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/draw", iid))
+	expected = `{"cards":[{"value":"KING","suite":"HEARTS","code":"KH"}]}` + "\n"
+	if expected != actual {
+		t.Errorf("Returned card was not put on top.\n\tExpected : %v\n\tGot      : %v", expected, actual)
+	}
+}
+
+// You can ask for them to go on the bottom instead.
+func TestReturnCardsToTheBottomOfADeck(t *testing.T) {
+	iid := app.NewDeck("AS KH 8C", false)
+	DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/draw", iid))
+
+	_, status := DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/return?cards=AS&position=bottom", iid))
+
+	if status != http.StatusOK {
+		t.Errorf("Recived wrong status code. Expected %v, got %v.", http.StatusOK, status)
+	}
+
+	deck, _ := app.GetDeck(iid)
+	if deck.String() != "KH 8C AS" {
+		t.Errorf("Returned card was not put on the bottom.  Expected 'KH 8C AS', got '%v'", deck)
+	}
+}
+
+// Handing back a card that didn't come from the deck is a bad request.
+func TestReturnCardsThatWereNotDrawn(t *testing.T) {
+	iid := app.NewDeck("AS KH 8C", false)
+	DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/draw", iid))
+
+	for _, cards := range []string{"QD", "KH", "AS,AS", "ZZ"} {
+		_, status := DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/return?cards=%v", iid, cards))
+		if status != http.StatusBadRequest {
+			t.Errorf("Returning %v: expected %v, got %v.", cards, http.StatusBadRequest, status)
+		}
+	}
+}
+
+// So is asking for a position that doesn't exist, or not saying which cards to return.
+func TestReturnCardsWithBadParameters(t *testing.T) {
+	iid := app.NewDeck("AS KH 8C", false)
+	DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/draw", iid))
+
+	_, status := DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/return?cards=AS&position=middle", iid))
+	if status != http.StatusBadRequest {
+		t.Errorf("Recived wrong status code for a bad position. Expected %v, got %v.", http.StatusBadRequest, status)
+	}
+
+	_, status = DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/return", iid))
+	if status != http.StatusBadRequest {
+		t.Errorf("Recived wrong status code for no cards. Expected %v, got %v.", http.StatusBadRequest, status)
+	}
+}
+
+// And of course the deck has to exist.
+func TestReturnCardsToAnInvalidDeck(t *testing.T) {
+	_, status := DoRequest(t, "POST", "/api/v1/decks/INVALID_ID/return?cards=AS")
+
+	if status != http.StatusNotFound {
+		t.Errorf("Recived wrong status code. Expected %v, got %v.", http.StatusNotFound, status)
+	}
+}
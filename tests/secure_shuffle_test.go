@@ -0,0 +1,155 @@
+package tests
+
+import (
+	"fmt"
+	"github.com/GamalielMasters/toggleDecks"
+	"math"
+	"math/rand"
+	"net/http"
+	"testing"
+)
+
+// Test the cryptographically secure shuffle mode.
+
+// Tally how often each card lands in each position over many shuffles, and return the chi-squared statistic of the
+// tally against every card being equally likely in every position, along with its degrees of freedom.
+func positionChiSquared(cards string, shuffles int, shuffle func(d *toggleDecks.Deck)) (chiSquared float64, degrees int) {
+	reference := toggleDecks.CreateDeck(cards)
+	n := reference.Len()
+	index := map[toggleDecks.Card]int{}
+	for i, c := range reference.Cards {
+		index[c] = i
+	}
+
+	counts := make([][]int, n)
+	for i := range counts {
+		counts[i] = make([]int, n)
+	}
+
+	for s := 0; s < shuffles; s++ {
+		deck := toggleDecks.CreateDeck(cards)
+		shuffle(deck)
+		for position, c := range deck.Cards {
+			counts[index[c]][position]++
+		}
+	}
+
+	expected := float64(shuffles) / float64(n)
+	for _, row := range counts {
+		for _, observed := range row {
+			chiSquared += (float64(observed) - expected) * (float64(observed) - expected) / expected
+		}
+	}
+
+	// Every row and every column of the table sums to the number of shuffles.
+	return chiSquared, (n - 1) * (n - 1)
+}
+
+// The chi-squared value that a fair shuffle stays under with probability 1 - 1/10000, by the Wilson-Hilferty
+// approximation.  A failure here is a 1 in 10000 fluke at worst.
+func chiSquaredCritical(degrees int) float64 {
+	const z = 3.719
+	k := float64(degrees)
+	return k * math.Pow(1-2/(9*k)+z*math.Sqrt(2/(9*k)), 3)
+}
+
+// Secure shuffles put every card in every position equally often.
+func TestSecureShuffleHasNoPositionalBias(t *testing.T) {
+	shuffles := 5200
+	if testing.Short() {
+		shuffles = 1040
+	}
+
+	chiSquared, degrees := positionChiSquared(toggleDecks.STANDARD_DECK, shuffles, func(d *toggleDecks.Deck) {
+		d.ShuffleMode = toggleDecks.ShuffleSecure
+		d.Shuffle()
+	})
+
+	if critical := chiSquaredCritical(degrees); chiSquared > critical {
+		t.Errorf("Secure shuffle is biased: chi-squared %.1f exceeds %.1f for %v degrees of freedom.", chiSquared, critical, degrees)
+	}
+}
+
+// The same holds for a small deck, where a biased shuffle would show up quickest.
+func TestSecureShuffleOfASmallDeckHasNoPositionalBias(t *testing.T) {
+	chiSquared, degrees := positionChiSquared("AS KH 8C QD 2S", 20000, func(d *toggleDecks.Deck) {
+		d.ShuffleMode = toggleDecks.ShuffleSecure
+		d.Shuffle()
+	})
+
+	if critical := chiSquaredCritical(degrees); chiSquared > critical {
+		t.Errorf("Secure shuffle is biased: chi-squared %.1f exceeds %.1f for %v degrees of freedom.", chiSquared, critical, degrees)
+	}
+}
+
+// And the check above really would catch a biased shuffle: the classic mistake of swapping each card with any card in
+// the deck, rather than one at or before it, fails it handily.
+func TestPositionalBiasCheckCatchesANaiveShuffle(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	chiSquared, degrees := positionChiSquared("AS KH 8C QD 2S", 20000, func(d *toggleDecks.Deck) {
+		for i := range d.Cards {
+			d.Swap(i, r.Intn(len(d.Cards)))
+		}
+	})
+
+	if critical := chiSquaredCritical(degrees); chiSquared <= critical {
+		t.Errorf("Naive shuffle passed the bias check: chi-squared %.1f under %.1f.", chiSquared, critical)
+	}
+}
+
+// A secure deck is created by asking for it, and says so in its details.  It has no seed to report.
+func TestCreateSecureDeck(t *testing.T) {
+	actual, status := DoCreateRequest(t, "POST", "/api/v1/decks?shuffle=true&shuffle_mode=secure")
+
+	if status != http.StatusOK {
+		t.Errorf("Recived wrong status code. Expected %v, got %v.", http.StatusOK, status)
+	}
+
+	expected := `{"deck_id":"a251071b-662f-44b6-ba11-e24863039c59","shuffled":true,"remaining":52,"version":1,"shuffle_mode":"secure"}` + "\n"
+	if expected != actual {
+		t.Errorf("Wrong result returned.\n\tExpected : %v\n\tGot      : %v", expected, actual)
+	}
+
+	deck, _ := app.GetDeck("a251071b-662f-44b6-ba11-e24863039c59")
+	if deck.String() == toggleDecks.STANDARD_DECK {
+		t.Error("Secure deck was not shuffled.")
+	}
+}
+
+// Every later shuffle of a secure deck is secure too, so it can't be given a seed.
+func TestSecureDecksRefuseSeeds(t *testing.T) {
+	_, status := DoRequest(t, "POST", "/api/v1/decks?shuffle_mode=secure&seed=42")
+	if status != http.StatusBadRequest {
+		t.Errorf("Recived wrong status code creating. Expected %v, got %v.", http.StatusBadRequest, status)
+	}
+
+	DoCreateRequest(t, "POST", "/api/v1/decks?shuffle_mode=secure")
+	iid := "a251071b-662f-44b6-ba11-e24863039c59"
+
+	for _, mode := range []string{"remaining", "all"} {
+		_, status = DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/shuffle?mode=%v&seed=42", iid, mode))
+		if status != http.StatusBadRequest {
+			t.Errorf("Recived wrong status code shuffling %v. Expected %v, got %v.", mode, http.StatusBadRequest, status)
+		}
+	}
+
+	deck, _ := app.GetDeck(iid)
+	if deck.String() != toggleDecks.STANDARD_DECK || deck.Shuffled {
+		t.Error("Refused seeded shuffle changed the deck anyway.")
+	}
+
+	actual, status := DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/shuffle", iid))
+	expected := fmt.Sprintf(`{"deck_id":"%v","shuffled":true,"remaining":52,"version":1,"shuffle_mode":"secure"}`+"\n", iid)
+	if status != http.StatusOK || expected != actual {
+		t.Errorf("Wrong result returned.\n\tExpected : %v\n\tGot      : %v", expected, actual)
+	}
+}
+
+// Only shuffle modes that exist can be asked for.
+func TestCreateDeckWithABadShuffleMode(t *testing.T) {
+	_, status := DoRequest(t, "POST", "/api/v1/decks?shuffle_mode=quantum")
+
+	if status != http.StatusBadRequest {
+		t.Errorf("Recived wrong status code. Expected %v, got %v.", http.StatusBadRequest, status)
+	}
+}
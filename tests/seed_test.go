@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"fmt"
+	"github.com/GamalielMasters/toggleDecks"
+	"net/http"
+	"testing"
+)
+
+// Test reproducible, seeded, shuffles.
+
+// The same cards shuffled with the same seed always come out the same.
+func TestSameSeedGivesTheSameOrder(t *testing.T) {
+	first := toggleDecks.CreateFullDeck()
+	first.ShuffleWithSeed(42)
+	second := toggleDecks.CreateFullDeck()
+	second.ShuffleWithSeed(42)
+
+	if first.String() != second.String() {
+		t.Errorf("Decks shuffled with the same seed differ.\n\t%v\n\t%v", first, second)
+	}
+
+	if first.Seed != 42 {
+		t.Errorf("Deck did not remember its seed. Expected 42, got %v", first.Seed)
+	}
+
+	third := toggleDecks.CreateFullDeck()
+	third.ShuffleWithSeed(43)
+	if first.String() == third.String() {
+		t.Error("Decks shuffled with different seeds came out the same.")
+	}
+}
+
+// Without a seed, decks shuffled at the same moment still come out differently.
+func TestGeneratedSeedsDiffer(t *testing.T) {
+	first := toggleDecks.CreateFullDeck()
+	second := toggleDecks.CreateFullDeck()
+	first.Shuffle()
+	second.Shuffle()
+
+	if first.Seed == second.Seed || first.String() == second.String() {
+		t.Error("Two decks shuffled together got the same seed and order.")
+	}
+}
+
+// A seed given when creating a deck is used for its shuffle, and reported back.
+func TestCreateDeckWithASeed(t *testing.T) {
+	actual, status := DoCreateRequest(t, "POST", "/api/v1/decks?seed=42")
+
+	if status != http.StatusOK {
+		t.Errorf("Recived wrong status code. Expected %v, got %v.", http.StatusOK, status)
+	}
+
+	expected := `{"deck_id":"a251071b-662f-44b6-ba11-e24863039c59","shuffled":true,"remaining":52,"version":1,"seed":42}` + "\n"
+	if expected != actual {
+		t.Errorf("Wrong result returned.\n\tExpected : %v\n\tGot      : %v", expected, actual)
+	}
+
+	reference := toggleDecks.CreateFullDeck()
+	reference.ShuffleWithSeed(42)
+	deck, _ := app.GetDeck("a251071b-662f-44b6-ba11-e24863039c59")
+	if deck.String() != reference.String() {
+		t.Errorf("Deck was not shuffled with the seed given.\n\tExpected: %v\n\tGot:      %v", reference, deck)
+	}
+}
+
+// Reshuffling can be given a seed too, which replaces the old one.
+func TestShuffleDeckWithASeed(t *testing.T) {
+	iid := app.NewDeck("", true)
+
+	actual, status := DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/shuffle?mode=all&seed=7", iid))
+
+	if status != http.StatusOK {
+		t.Errorf("Recived wrong status code. Expected %v, got %v.", http.StatusOK, status)
+	}
+
+	expected := fmt.Sprintf(`{"deck_id":"%v","shuffled":true,"remaining":52,"version":2,"seed":7}`+"\n", iid)
+	if expected != actual {
+		t.Errorf("Wrong result returned.\n\tExpected : %v\n\tGot      : %v", expected, actual)
+	}
+
+	reference := toggleDecks.CreateFullDeck()
+	reference.ShuffleWithSeed(7)
+	deck, _ := app.GetDeck(iid)
+	if deck.String() != reference.String() {
+		t.Errorf("Deck was not shuffled with the seed given.\n\tExpected: %v\n\tGot:      %v", reference, deck)
+	}
+}
+
+// A seed has to be a number.
+func TestBadSeeds(t *testing.T) {
+	iid := app.NewDeck("", false)
+
+	for _, url := range []string{"/api/v1/decks?seed=lucky", fmt.Sprintf("/api/v1/decks/%v/shuffle?seed=1.5", iid)} {
+		_, status := DoRequest(t, "POST", url)
+		if status != http.StatusBadRequest {
+			t.Errorf("%v: expected %v, got %v.", url, http.StatusBadRequest, status)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package tests
+
+import (
+	"fmt"
+	"github.com/GamalielMasters/toggleDecks"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// Test shoes: several decks combined into one.
+
+// A shoe of six standard decks holds six of every card.
+func TestCreateShoe(t *testing.T) {
+	actual, status := DoCreateRequest(t, "POST", "/api/v1/decks?deck_count=6&shuffle=true")
+
+	if status != http.StatusOK {
+		t.Errorf("Recived wrong status code. Expected %v, got %v.", http.StatusOK, status)
+	}
+
+	expected := `{"deck_id":"a251071b-662f-44b6-ba11-e24863039c59","shuffled":true,"remaining":312,"version":1,"seed":12345}` + "\n"
+	if expected != actual {
+		t.Errorf("Wrong result returned.\n\tExpected : %v\n\tGot      : %v", expected, actual)
+	}
+
+	deck, _ := app.GetDeck("a251071b-662f-44b6-ba11-e24863039c59")
+	if ok, e, a := DeckContainsCards(deck, strings.Repeat(toggleDecks.STANDARD_DECK+" ", 5)+toggleDecks.STANDARD_DECK); !ok {
+		t.Errorf("Shoe has the wrong cards.\n\tExpected: %v\n\tGot:      %v", e, a)
+	}
+
+	// Shuffled together, not one deck after another.
+	sources := map[int]bool{}
+	for _, c := range deck.Cards[:52] {
+		sources[c.Source()] = true
+	}
+	if len(sources) == 1 {
+		t.Error("Shoe decks were not shuffled together.")
+	}
+}
+
+// A custom shoe is made of copies of the custom cards.
+func TestCreateCustomShoe(t *testing.T) {
+	actual, _ := DoCreateRequest(t, "POST", "/api/v1/decks?deck_count=3&cards=AS,KH")
+
+	expected := `{"deck_id":"a251071b-662f-44b6-ba11-e24863039c59","shuffled":false,"remaining":6,"version":0}` + "\n"
+	if expected != actual {
+		t.Errorf("Wrong result returned.\n\tExpected : %v\n\tGot      : %v", expected, actual)
+	}
+
+	actual, _ = DoRequest(t, "GET", "/api/v1/decks/a251071b-662f-44b6-ba11-e24863039c59")
+	if strings.Count(actual, `"code":"AS"`) != 3 || strings.Count(actual, `"code":"KH"`) != 3 {
+		t.Errorf("Custom shoe does not hold three of each card: %v", actual)
+	}
+}
+
+// Only sensible numbers of decks can be combined.
+func TestCreateShoeWithABadDeckCount(t *testing.T) {
+	for _, count := range []string{"0", "-2", "17", "six"} {
+		_, status := DoRequest(t, "POST", "/api/v1/decks?deck_count="+count)
+		if status != http.StatusBadRequest {
+			t.Errorf("Recived wrong status code for deck_count=%v. Expected %v, got %v.", count, http.StatusBadRequest, status)
+		}
+	}
+}
+
+// Cards in a shoe look just like any other card, but the history can say which deck each came from.
+func TestShoeHistoryWithSources(t *testing.T) {
+	DoCreateRequest(t, "POST", "/api/v1/decks?deck_count=2&cards=AS")
+	iid := "a251071b-662f-44b6-ba11-e24863039c59"
+
+	actual, _ := DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/draw?count=2", iid))
+	expected := `{"cards":[{"value":"ACE","suite":"SPADES","code":"AS"},{"value":"ACE","suite":"SPADES","code":"AS"}]}` + "\n"
+	if expected != actual {
+		t.Errorf("Wrong result returned.\n\tExpected : %v\n\tGot      : %v", expected, actual)
+	}
+
+	// Returned by code, the first copy drawn goes back.
+	DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/return?cards=AS", iid))
+
+	actual, _ = DoRequest(t, "GET", fmt.Sprintf("/api/v1/decks/%v/history?sources=true", iid))
+	for _, want := range []string{
+		`"original":[{"value":"ACE","suite":"SPADES","code":"AS","source":1},{"value":"ACE","suite":"SPADES","code":"AS","source":2}]`,
+		`"action":"draw","count":2,"cards":[{"value":"ACE","suite":"SPADES","code":"AS","source":1},{"value":"ACE","suite":"SPADES","code":"AS","source":2}]`,
+		`"action":"return","count":1,"cards":[{"value":"ACE","suite":"SPADES","code":"AS","source":1}]`,
+	} {
+		if !strings.Contains(actual, want) {
+			t.Errorf("History is missing %v\n\tGot: %v", want, actual)
+		}
+	}
+
+	actual, _ = DoRequest(t, "GET", fmt.Sprintf("/api/v1/decks/%v/history", iid))
+	if strings.Contains(actual, "source") {
+		t.Errorf("History gave sources without being asked: %v", actual)
+	}
+
+	deck, _ := app.GetDeck(iid)
+	if drawn := deck.Drawn(); len(drawn) != 1 || drawn[0].Source() != 2 {
+		t.Errorf("Expected the copy from deck 2 to still be drawn, got %v", drawn)
+	}
+}
+
+// Cards moved between piles by code keep their source.
+func TestShoeCardsMoveBetweenPiles(t *testing.T) {
+	deck := toggleDecks.CreateShoe("AS KH", 2)
+	_ = deck.CreatePile("hand")
+	_ = deck.CreatePile("discard")
+	_, _ = deck.DrawToPile("hand", 4)
+
+	moved, err := deck.MovePileCards("hand", "discard", 0, []toggleDecks.Card{"KH"})
+	if err != nil || len(moved) != 1 || moved[0].Code() != "KH" || moved[0].Source() != 1 {
+		t.Errorf("Expected the first KH in the hand to move, got %v (%v)", moved, err)
+	}
+
+	hand, _ := deck.GetPile("hand")
+	if fmt.Sprint(hand) != "[AS AS KH]" {
+		t.Errorf("Wrong cards left in the hand: %v", hand)
+	}
+}
@@ -0,0 +1,98 @@
+package tests
+
+import (
+	"fmt"
+	"github.com/GamalielMasters/toggleDecks"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// Shuffling a deck part way through only shuffles what's left in it.
+func TestShuffleRemainingCards(t *testing.T) {
+	PatchSeed()
+	defer UnPatchSeed()
+	iid := app.NewDeck("", false)
+	DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/draw?count=12", iid))
+
+	actual, status := DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/shuffle", iid))
+
+	if status != http.StatusOK {
+		t.Errorf("Recived wrong status code. Expected %v, got %v.", http.StatusOK, status)
+	}
+
+	expected := fmt.Sprintf(`{"deck_id":"%v","shuffled":true,"remaining":40,"version":2,"seed":12345}`+"\n", iid)
+	if expected != actual {
+		t.Errorf("Wrong result returned.\n\tExpected : %v\n\tGot      : %v", expected, actual)
+	}
+
+	deck, _ := app.GetDeck(iid)
+	if equal, expected, got := DeckContainsCards(deck, strings.Join(strings.Split(toggleDecks.STANDARD_DECK, " ")[12:], " ")); !equal {
+		t.Errorf("Deck does not contain the remaining cards.\n\tExpected: '%v'\n\tGot:      '%v'", expected, got)
+	}
+}
+
+// But when the shoe runs out you can gather everything back up, drawn cards and piles included, and start again.
+func TestShuffleAllCardsBackIntoTheDeck(t *testing.T) {
+	PatchSeed()
+	defer UnPatchSeed()
+	iid := app.NewDeck("", false)
+	DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/piles/discard", iid))
+	DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/piles/discard/draw?count=10", iid))
+	DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/draw?count=40", iid))
+
+	actual, status := DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/shuffle?mode=all", iid))
+
+	if status != http.StatusOK {
+		t.Errorf("Recived wrong status code. Expected %v, got %v.", http.StatusOK, status)
+	}
+
+	expected := fmt.Sprintf(`{"deck_id":"%v","shuffled":true,"remaining":52,"version":4,"seed":12345}`+"\n", iid)
+	if expected != actual {
+		t.Errorf("Wrong result returned.\n\tExpected : %v\n\tGot      : %v", expected, actual)
+	}
+
+	deck, _ := app.GetDeck(iid)
+	if equal, expected, got := DeckContainsCards(deck, toggleDecks.STANDARD_DECK); !equal {
+		t.Errorf("Deck does not contain every card.\n\tExpected: '%v'\n\tGot:      '%v'", expected, got)
+	}
+
+	if deck.String() == toggleDecks.STANDARD_DECK {
+		t.Error("Gathered cards were not shuffled.")
+	}
+
+	if discard, _ := deck.GetPile("discard"); len(discard) != 0 {
+		t.Errorf("Discard pile still holds %v cards.", len(discard))
+	}
+
+	if drawn := deck.Drawn(); len(drawn) != 0 {
+		t.Errorf("Deck still thinks %v cards are drawn.", len(drawn))
+	}
+}
+
+// A custom deck gathers back up into what it started as, not a full deck.
+func TestShuffleAllCardsOfACustomDeck(t *testing.T) {
+	iid := app.NewDeck("AS KH 8C", false)
+	DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/draw?count=2", iid))
+	DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/shuffle?mode=all", iid))
+
+	deck, _ := app.GetDeck(iid)
+	if equal, expected, got := DeckContainsCards(deck, "AS KH 8C"); !equal {
+		t.Errorf("Deck does not contain its original cards.\n\tExpected: '%v'\n\tGot:      '%v'", expected, got)
+	}
+}
+
+// Shuffle modes that don't exist are a bad request, and decks that don't exist aren't found.
+func TestShuffleWithBadParameters(t *testing.T) {
+	iid := app.NewDeck("", false)
+
+	_, status := DoRequest(t, "POST", fmt.Sprintf("/api/v1/decks/%v/shuffle?mode=sideways", iid))
+	if status != http.StatusBadRequest {
+		t.Errorf("Recived wrong status code. Expected %v, got %v.", http.StatusBadRequest, status)
+	}
+
+	_, status = DoRequest(t, "POST", "/api/v1/decks/INVALID_ID/shuffle")
+	if status != http.StatusNotFound {
+		t.Errorf("Recived wrong status code. Expected %v, got %v.", http.StatusNotFound, status)
+	}
+}
@@ -1,23 +1,26 @@
 package tests
 
 import (
+	"encoding/json"
 	"github.com/GamalielMasters/toggleDecks"
 	"net/http"
 	"net/http/httptest"
 	"sort"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 // The testing context router.
 var app *toggleDecks.App
 
 func init() {
-	app = toggleDecks.NewApp()
+	app = toggleDecks.NewApp(toggleDecks.NewMemoryDeckStore())
 }
 
 // Convert the deck into a string for comparison purposes.
-func DeckToSSortedString(d toggleDecks.Deck) string {
+func DeckToSSortedString(d *toggleDecks.Deck) string {
 	return SortDeckString(d.String())
 }
 
@@ -29,7 +32,7 @@ func SortDeckString(expected string) string {
 }
 
 // Compare a deck to a string of deck codes to ensure that they contain the same cards, not necessarily in the same order.
-func DeckContainsCards(d toggleDecks.Deck, expected string) (bool, string, string) {
+func DeckContainsCards(d *toggleDecks.Deck, expected string) (bool, string, string) {
 	theDeck := DeckToSSortedString(d)
 	expectedDeck := SortDeckString(expected)
 	return theDeck == expectedDeck, expectedDeck, theDeck
@@ -52,26 +55,146 @@ func UnPatchUID() {
 	toggleDecks.TheGuidProvider = toggleDecks.GuidIdProvider{}
 }
 
+// Mocking support for shuffle seeds
+type SeedMock struct{}
+
+func (m SeedMock) GenerateSeed() int64 {
+	return 12345
+}
+
+// Patch the seed provider to return the same seed every time, so shuffles are predictable.
+func PatchSeed() {
+	toggleDecks.TheSeedProvider = SeedMock{}
+}
+
+// Unpatch the seed provider so it goes back to providing random seeds.
+func UnPatchSeed() {
+	toggleDecks.TheSeedProvider = toggleDecks.RandomSeedProvider{}
+}
+
+// Mocking support for the clock.  Time only moves when the test says so.
+type ClockMock struct {
+	mu      sync.Mutex
+	current time.Time
+	tickers []chan time.Time
+}
+
+func (c *ClockMock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.current
+}
+
+func (c *ClockMock) Tick(d time.Duration) (<-chan time.Time, func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ticks := make(chan time.Time)
+	c.tickers = append(c.tickers, ticks)
+	return ticks, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		for i, t := range c.tickers {
+			if t == ticks {
+				c.tickers = append(c.tickers[:i], c.tickers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Move the clock forward, and deliver a tick to everything waiting on one.  Returns once every tick is received.
+func (c *ClockMock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.current = c.current.Add(d)
+	now := c.current
+	tickers := append([]chan time.Time{}, c.tickers...)
+	c.mu.Unlock()
+
+	for _, t := range tickers {
+		t <- now
+	}
+}
+
+// Patch the clock to a mock that starts at a fixed time and only moves when advanced.
+func PatchClock() *ClockMock {
+	clock := &ClockMock{current: time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)}
+	toggleDecks.TheClock = clock
+	return clock
+}
+
+// Unpatch the clock so it goes back to the system time.
+func UnPatchClock() {
+	toggleDecks.TheClock = toggleDecks.SystemClock{}
+}
+
 // Setup for creating a deck, and execute a deck creation request.
 func DoCreateRequest(t *testing.T, method string, url string) (body string, result int) {
 	app.ClearTheDatabase()
 	PatchUID()
 	defer UnPatchUID()
+	PatchSeed()
+	defer UnPatchSeed()
 
 	return DoRequest(t, method, url)
 }
 
 // Execute a request and return the results.
 func DoRequest(t *testing.T, method string, url string) (body string, result int) {
-	req, err := http.NewRequest(method, url, nil)
+	return DoBodyRequest(t, method, url, "")
+}
+
+// Execute a request with a body and return the results.
+func DoBodyRequest(t *testing.T, method string, url string, requestBody string) (body string, result int) {
+	rr := DoHeaderRequest(t, method, url, requestBody, nil)
+	return rr.Body.String(), rr.Code
+}
 
+// Execute a request with a body and headers, and return the recorded response.  Headers with empty values aren't sent.
+func DoHeaderRequest(t *testing.T, method string, url string, requestBody string, headers map[string]string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest(method, url, strings.NewReader(requestBody))
 	if err != nil {
 		t.Fatal(err)
 	}
+
+	for name, value := range headers {
+		if len(value) != 0 {
+			req.Header.Set(name, value)
+		}
+	}
+
 	rr := httptest.NewRecorder()
 	app.Router.ServeHTTP(rr, req)
-	result = rr.Code
+	return rr
+}
+
+// Check that a response is a JSON error envelope with the expected status and code, and return the envelope.
+func CheckErrorResponse(t *testing.T, rr *httptest.ResponseRecorder, status int, code toggleDecks.ErrorCode) (message toggleDecks.RestErrorMessage) {
+	t.Helper()
 
-	body = rr.Body.String()
+	if contentType := rr.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("The error was sent as %q rather than JSON.", contentType)
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &message); err != nil {
+		t.Fatalf("The error was not a JSON envelope: %v\n\t%v", err, rr.Body.String())
+	}
+
+	if rr.Code != status || message.Code != code {
+		t.Errorf("Wrong error.  Expected %v %v, got %v %v", status, code, rr.Code, message.Code)
+	}
 	return
 }
+
+// Setup for creating a deck, and execute a deck creation request with a JSON body.
+func DoCreateJSONRequest(t *testing.T, requestBody string) (body string, result int) {
+	app.ClearTheDatabase()
+	PatchUID()
+	defer UnPatchUID()
+	PatchSeed()
+	defer UnPatchSeed()
+
+	rr := DoHeaderRequest(t, "POST", "/api/v1/decks", requestBody, map[string]string{"Content-Type": "application/json"})
+	return rr.Body.String(), rr.Code
+}